@@ -12,15 +12,16 @@ import (
 )
 
 var convertCmd = &cobra.Command{
-	Use:   "convert <input-dir>",
+	Use:   "convert [input-dir]",
 	Short: "Convert HTML files to Markdown",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MaximumNArgs(1),
 	RunE:  runConvert,
 }
 
 var (
 	convertOutput       string
 	convertZip          string
+	convertWARC         []string
 	convertWorkers      int
 	convertStripTags    string
 	convertStripClasses string
@@ -32,6 +33,7 @@ func init() {
 
 	convertCmd.Flags().StringVarP(&convertOutput, "output", "o", "md_output", "directory for Markdown output")
 	convertCmd.Flags().StringVar(&convertZip, "zip", "", "ZIP archive containing HTML files")
+	convertCmd.Flags().StringArrayVar(&convertWARC, "warc", nil, "WARC file (.warc or .warc.gz) whose HTML responses are converted (repeatable)")
 	convertCmd.Flags().IntVar(&convertWorkers, "max-workers", 4, "number of parallel conversion workers")
 	convertCmd.Flags().StringVar(&convertStripTags, "strip-tags", "", "comma-separated HTML tags to remove (e.g. nav,footer)")
 	convertCmd.Flags().StringVar(&convertStripClasses, "strip-classes", "", "comma-separated CSS classes to remove")
@@ -39,12 +41,22 @@ func init() {
 }
 
 func runConvert(cmd *cobra.Command, args []string) error {
-	inputDir := args[0]
+	var inputDir string
+	switch {
+	case len(args) == 1:
+		inputDir = args[0]
+	case convertZip == "" && len(convertWARC) == 0:
+		return fmt.Errorf("convert needs an input directory, --zip or --warc")
+	}
+	if convertZip != "" && len(convertWARC) > 0 {
+		return fmt.Errorf("--zip and --warc cannot be used together")
+	}
 
 	cfg := converter.ConvertConfig{
 		InputDir:  inputDir,
 		OutputDir: convertOutput,
 		ZipPath:   convertZip,
+		WARCPaths: convertWARC,
 		Workers:   convertWorkers,
 	}
 
@@ -3,6 +3,11 @@ package cmd
 import (
 	"fmt"
 	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"golm-connector/internal/crawler"
@@ -12,42 +17,187 @@ import (
 )
 
 var crawlCmd = &cobra.Command{
-	Use:   "crawl <url>",
+	Use:   "crawl <url>...",
 	Short: "BFS-crawl a website and save HTML pages",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	RunE:  runCrawl,
 }
 
 var (
 	crawlOutput      string
+	crawlScope       string
+	crawlAllowHosts  []string
 	crawlMaxPages    int
+	crawlOrder       string
+	crawlWeights     []string
+	crawlMaxDepth    int
 	crawlDelay       time.Duration
 	crawlConcurrency int
+	crawlMaxPerHost  int
+	crawlHostLimits  []string
+	crawlAdaptive    bool
 	crawlCacheDir    string
+	crawlCacheTTL    time.Duration
+	crawlMaxRetries  int
+	crawlBackoff     time.Duration
 	crawlRetryReport string
+	crawlResume      bool
+	crawlIncremental bool
+	crawlPrune       bool
+	crawlFollowRels  bool
+	crawlIgnoreRobot bool
+	crawlUseSitemaps bool
+	crawlSitemaps    []string
+	crawlSitemapOnly bool
+	crawlInclude     []string
+	crawlExclude     []string
+	crawlAllowTypes  []string
+	crawlDropParams  []string
+	crawlSortQuery   bool
+	crawlIgnoreQuery bool
+	crawlFilesDir    string
+	crawlWARCDir     string
+	crawlWARCMaxSize string
+	crawlSeedsFile   string
+	crawlHeaders     []string
+	crawlBearer      string
+	crawlBasicAuth   string
+	crawlCookies     string
+	crawlTimeout     time.Duration
+	crawlProxy       string
+	crawlCACerts     []string
+	crawlClientCert  string
+	crawlClientKey   string
+	crawlInsecure    bool
+	crawlMaxBody     string
 )
 
 func init() {
 	rootCmd.AddCommand(crawlCmd)
 
 	crawlCmd.Flags().StringVarP(&crawlOutput, "output", "o", "html_output", "directory for saved HTML files")
+	crawlCmd.Flags().StringVar(&crawlSeedsFile, "seeds-file", "", "file with additional seed URLs, one per line")
+	crawlCmd.Flags().StringVar(&crawlScope, "scope", "", "link scope: host (seed host only), domain (all subdomains) or hosts (seeds plus --allow-host)")
+	crawlCmd.Flags().StringArrayVar(&crawlAllowHosts, "allow-host", nil, "extra host[/path-prefix] to crawl, e.g. api.example.com/v2 (repeatable, implies --scope hosts)")
 	crawlCmd.Flags().IntVar(&crawlMaxPages, "max-pages", 0, "maximum number of pages to crawl (0 = unlimited)")
+	crawlCmd.Flags().IntVar(&crawlMaxDepth, "max-depth", 0, "maximum link depth from the seed URL (0 = unlimited)")
+	crawlCmd.Flags().StringVar(&crawlOrder, "order", "", "crawl order: discovery, depth or priority (default discovery, or priority with --weight)")
+	crawlCmd.Flags().StringArrayVar(&crawlWeights, "weight", nil, `URL priority weight "pattern=weight", e.g. "/guide/*=2" or "*/release-notes/*=-1" (repeatable, implies --order priority)`)
 	crawlCmd.Flags().DurationVar(&crawlDelay, "delay", time.Second, "delay between requests (e.g. 1s, 500ms)")
 	crawlCmd.Flags().IntVar(&crawlConcurrency, "max-concurrency", 5, "number of parallel HTTP workers")
+	crawlCmd.Flags().IntVar(&crawlMaxPerHost, "max-per-host", 0, "maximum parallel requests to one host (0 = unlimited)")
+	crawlCmd.Flags().StringArrayVar(&crawlHostLimits, "host-limit", nil, `per-host override "pattern=delay[,max-in-flight]", e.g. "api.example.com=2s,1" (repeatable)`)
+	crawlCmd.Flags().BoolVar(&crawlAdaptive, "adaptive-delay", false, "slow down hosts whose latency or error rate rises")
+	crawlCmd.Flags().IntVar(&crawlMaxRetries, "max-retries", 3, "retries for transient failures (timeouts, resets, 429, 5xx)")
+	crawlCmd.Flags().DurationVar(&crawlBackoff, "retry-backoff", time.Second, "base delay for exponential retry backoff")
 	crawlCmd.Flags().StringVar(&crawlCacheDir, "cache-dir", "", "disk cache directory for HTTP responses")
+	crawlCmd.Flags().DurationVar(&crawlCacheTTL, "cache-ttl", 0, "revalidate cached responses older than this (0 = never revalidate)")
 	crawlCmd.Flags().StringVar(&crawlRetryReport, "retry-from-report", "", "retry failed URLs from a previous report JSON")
+	crawlCmd.Flags().BoolVar(&crawlResume, "resume", false, "continue an interrupted crawl from the state saved in the output directory")
+	crawlCmd.Flags().BoolVar(&crawlIncremental, "incremental", false, "compare with the previous crawl in the output directory and rewrite only changed pages")
+	crawlCmd.Flags().BoolVar(&crawlPrune, "prune", false, "with --incremental, delete files of pages that were removed upstream")
+	crawlCmd.Flags().BoolVar(&crawlIgnoreRobot, "ignore-robots", false, "do not obey robots.txt, meta robots or rel=nofollow (only for sites you own)")
+	crawlCmd.Flags().BoolVar(&crawlFollowRels, "follow-link-rel", false, "also follow <link rel> next, prev and alternate hreflang links")
+	crawlCmd.Flags().BoolVar(&crawlUseSitemaps, "use-sitemaps", false, "seed the crawl from sitemaps listed in robots.txt (or /sitemap.xml)")
+	crawlCmd.Flags().StringArrayVar(&crawlSitemaps, "sitemap", nil, "explicit sitemap URL to seed from (repeatable)")
+	crawlCmd.Flags().BoolVar(&crawlSitemapOnly, "sitemap-only", false, "crawl only sitemap URLs without following links")
+	crawlCmd.Flags().StringArrayVar(&crawlInclude, "include", nil, "only crawl URLs matching this glob (or re:<regexp>) (repeatable)")
+	crawlCmd.Flags().StringArrayVar(&crawlExclude, "exclude", nil, "skip URLs matching this glob (or re:<regexp>) (repeatable)")
+	crawlCmd.Flags().StringSliceVar(&crawlDropParams, "drop-param", crawler.DefaultTrackingParams, "query parameters to strip from URLs; trailing * matches a prefix (repeatable)")
+	crawlCmd.Flags().BoolVar(&crawlSortQuery, "sort-query", false, "sort query parameters so parameter order does not create duplicate URLs")
+	crawlCmd.Flags().BoolVar(&crawlIgnoreQuery, "ignore-query", false, "drop query strings from URLs entirely")
+	crawlCmd.Flags().StringSliceVar(&crawlAllowTypes, "allow-type", nil, "non-HTML media types to save, e.g. application/pdf,text/plain (repeatable)")
+	crawlCmd.Flags().StringVar(&crawlFilesDir, "files-dir", "", "directory for saved non-HTML files (default <output>/_files)")
+	crawlCmd.Flags().StringVar(&crawlWARCDir, "warc", "", "also archive every fetched response as WARC files in this directory")
+	crawlCmd.Flags().StringVar(&crawlWARCMaxSize, "warc-max-size", "1GB", "size after which a new WARC file is started, e.g. 100MB")
+	crawlCmd.Flags().StringArrayVarP(&crawlHeaders, "header", "H", nil, `extra request header "Name: value" sent to in-scope hosts (repeatable)`)
+	crawlCmd.Flags().StringVar(&crawlBearer, "bearer-token", "", "bearer token sent to in-scope hosts")
+	crawlCmd.Flags().StringVar(&crawlBasicAuth, "basic-auth", "", `HTTP basic auth "user:password" for in-scope hosts`)
+	crawlCmd.Flags().StringVar(&crawlCookies, "cookies", "", "Netscape cookies.txt file whose cookies are sent to in-scope hosts")
+	crawlCmd.Flags().DurationVar(&crawlTimeout, "timeout", 30*time.Second, "timeout for a single HTTP request")
+	crawlCmd.Flags().StringVar(&crawlProxy, "proxy", "", "HTTP(S) proxy URL (default from HTTP_PROXY/HTTPS_PROXY)")
+	crawlCmd.Flags().StringArrayVar(&crawlCACerts, "ca-cert", nil, "extra PEM CA certificate file to trust (repeatable)")
+	crawlCmd.Flags().StringVar(&crawlClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	crawlCmd.Flags().StringVar(&crawlClientKey, "client-key", "", "PEM private key for --client-cert")
+	crawlCmd.Flags().BoolVar(&crawlInsecure, "insecure-skip-verify", false, "do not verify TLS certificates (testing only)")
+	crawlCmd.Flags().StringVar(&crawlMaxBody, "max-body-size", "0", "largest response to download, e.g. 50MB (0 = unlimited)")
 }
 
 func runCrawl(cmd *cobra.Command, args []string) error {
-	startURL := args[0]
+	seeds, err := loadSeeds(args, crawlSeedsFile)
+	if err != nil {
+		return err
+	}
 
 	cfg := crawler.CrawlConfig{
-		StartURL:       startURL,
-		OutputDir:      crawlOutput,
-		MaxPages:       crawlMaxPages,
-		Delay:          crawlDelay,
-		MaxConcurrency: crawlConcurrency,
-		CacheDir:       crawlCacheDir,
+		StartURL:           seeds[0],
+		Seeds:              seeds[1:],
+		OutputDir:          crawlOutput,
+		ScopeMode:          crawler.ScopeMode(crawlScope),
+		AllowedHosts:       crawlAllowHosts,
+		MaxPages:           crawlMaxPages,
+		MaxDepth:           crawlMaxDepth,
+		Order:              crawler.FrontierOrder(crawlOrder),
+		Delay:              crawlDelay,
+		MaxConcurrency:     crawlConcurrency,
+		MaxPerHost:         crawlMaxPerHost,
+		AdaptiveDelay:      crawlAdaptive,
+		CacheDir:           crawlCacheDir,
+		CacheTTL:           crawlCacheTTL,
+		MaxRetries:         crawlMaxRetries,
+		RetryBackoff:       crawlBackoff,
+		Resume:             crawlResume,
+		Incremental:        crawlIncremental,
+		Prune:              crawlPrune,
+		IgnoreRobots:       crawlIgnoreRobot,
+		FollowLinkRels:     crawlFollowRels,
+		UseSitemaps:        crawlUseSitemaps,
+		SitemapURLs:        crawlSitemaps,
+		SitemapOnly:        crawlSitemapOnly,
+		Include:            crawlInclude,
+		Exclude:            crawlExclude,
+		AllowTypes:         crawlAllowTypes,
+		FilesDir:           crawlFilesDir,
+		WARCDir:            crawlWARCDir,
+		Timeout:            crawlTimeout,
+		Proxy:              crawlProxy,
+		CACertFiles:        crawlCACerts,
+		ClientCert:         crawlClientCert,
+		ClientKey:          crawlClientKey,
+		InsecureSkipVerify: crawlInsecure,
+		Normalize: crawler.NormalizeOptions{
+			DropParams:  crawlDropParams,
+			SortQuery:   crawlSortQuery,
+			IgnoreQuery: crawlIgnoreQuery,
+		},
+	}
+
+	maxBody, err := parseByteSize(crawlMaxBody)
+	if err != nil {
+		return fmt.Errorf("invalid --max-body-size: %w", err)
+	}
+	cfg.MaxBodySize = maxBody
+	if cfg.WARCMaxSize, err = parseByteSize(crawlWARCMaxSize); err != nil {
+		return fmt.Errorf("invalid --warc-max-size: %w", err)
+	}
+
+	if cfg.HostLimits, err = parseHostLimits(crawlHostLimits); err != nil {
+		return err
+	}
+	if cfg.URLWeights, err = parseURLWeights(crawlWeights); err != nil {
+		return err
+	}
+
+	if err := setCrawlAuth(&cfg, crawlHeaders, crawlBearer, crawlBasicAuth, crawlCookies); err != nil {
+		return err
+	}
+
+	if crawlPrune && !crawlIncremental {
+		return fmt.Errorf("--prune requires --incremental")
+	}
+
+	if crawlRetryReport != "" && crawlResume {
+		return fmt.Errorf("--retry-from-report and --resume cannot be used together")
 	}
 
 	if crawlRetryReport != "" {
@@ -69,19 +219,7 @@ func runCrawl(cmd *cobra.Command, args []string) error {
 	result, err := crawler.Run(cmd.Context(), cfg)
 
 	if step != nil {
-		for _, saved := range result.Saved {
-			step.URLs = append(step.URLs, report.URLStatus{
-				URL:    saved,
-				Status: report.StatusOK,
-			})
-		}
-		for u, e := range result.Errors {
-			step.URLs = append(step.URLs, report.URLStatus{
-				URL:    u,
-				Status: report.StatusError,
-				Error:  e,
-			})
-		}
+		addCrawlResult(step, result)
 		report.Finish(step)
 		if writeErr := report.Write(rep, reportPath); writeErr != nil {
 			slog.Warn("failed to write report", "err", writeErr)
@@ -92,6 +230,195 @@ func runCrawl(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("Crawl complete: %d saved, %d errors\n", len(result.Saved), len(result.Errors))
+	fmt.Printf("Crawl complete: %d saved, %d skipped, %d errors\n",
+		len(result.Saved), len(result.Skipped), len(result.Errors))
+	if crawlIncremental {
+		printChanges(result)
+	}
+	for _, f := range result.WARCFiles {
+		fmt.Printf("  WARC: %s\n", f)
+	}
 	return nil
 }
+
+// loadSeeds returns the seed URLs from args followed by those in seedsFile
+// (one per line; blank lines and lines starting with "#" are ignored).
+func loadSeeds(args []string, seedsFile string) ([]string, error) {
+	seeds := append([]string(nil), args...)
+	if seedsFile != "" {
+		data, err := os.ReadFile(seedsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read seeds file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				seeds = append(seeds, line)
+			}
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no seed URL given: pass one or more URLs or --seeds-file")
+	}
+	return seeds, nil
+}
+
+// setCrawlAuth parses the authentication flags into cfg.
+func setCrawlAuth(cfg *crawler.CrawlConfig, headers []string, bearer, basicAuth, cookies string) error {
+	if len(headers) > 0 {
+		cfg.Headers = make(http.Header, len(headers))
+		for _, h := range headers {
+			name, value, ok := strings.Cut(h, ":")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return fmt.Errorf(`invalid --header: want "Name: value"`)
+			}
+			cfg.Headers.Add(name, strings.TrimSpace(value))
+		}
+	}
+	if basicAuth != "" {
+		user, pass, ok := strings.Cut(basicAuth, ":")
+		if !ok || user == "" {
+			return fmt.Errorf(`invalid --basic-auth: want "user:password"`)
+		}
+		cfg.Username, cfg.Password = user, pass
+	}
+	cfg.BearerToken = bearer
+	cfg.CookieFile = cookies
+	return nil
+}
+
+// parseByteSize parses a size such as "512", "64KB", "50MB" or "1GB"
+// (binary units, case-insensitive, optional "iB" spelling).
+func parseByteSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{
+		{"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	} {
+		if rest, ok := strings.CutSuffix(v, u.suffix); ok {
+			v, mult = strings.TrimSpace(rest), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad size %q", s)
+	}
+	return n * mult, nil
+}
+
+// parseURLWeights parses the --weight flags, each "pattern=weight". The
+// last "=" separates the weight, so patterns may contain "=".
+func parseURLWeights(values []string) ([]crawler.URLWeight, error) {
+	var weights []crawler.URLWeight
+	for _, v := range values {
+		i := strings.LastIndex(v, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid --weight: want \"pattern=weight\", got %q", v)
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(v[i+1:]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --weight: bad weight in %q", v)
+		}
+		weights = append(weights, crawler.URLWeight{Pattern: strings.TrimSpace(v[:i]), Weight: w})
+	}
+	return weights, nil
+}
+
+// parseHostLimits parses the --host-limit flags.
+func parseHostLimits(values []string) ([]crawler.HostLimit, error) {
+	var limits []crawler.HostLimit
+	for _, v := range values {
+		l, err := parseHostLimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --host-limit: %w", err)
+		}
+		limits = append(limits, l)
+	}
+	return limits, nil
+}
+
+// parseHostLimit parses "pattern=delay[,max-in-flight]". An empty delay
+// keeps the global --delay, e.g. "*.example.com=,2".
+func parseHostLimit(s string) (crawler.HostLimit, error) {
+	pattern, spec, ok := strings.Cut(s, "=")
+	pattern = strings.TrimSpace(pattern)
+	if !ok || pattern == "" {
+		return crawler.HostLimit{}, fmt.Errorf("want \"pattern=delay[,max-in-flight]\", got %q", s)
+	}
+	l := crawler.HostLimit{Pattern: pattern, Delay: -1}
+	delay, inFlight, hasInFlight := strings.Cut(spec, ",")
+	if delay = strings.TrimSpace(delay); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return crawler.HostLimit{}, fmt.Errorf("bad delay %q", delay)
+		}
+		l.Delay = d
+	}
+	if hasInFlight {
+		n, err := strconv.Atoi(strings.TrimSpace(inFlight))
+		if err != nil || n < 0 {
+			return crawler.HostLimit{}, fmt.Errorf("bad max-in-flight %q", inFlight)
+		}
+		l.MaxInFlight = n
+	}
+	return l, nil
+}
+
+// printChanges prints how many pages an incremental crawl added, changed,
+// left unchanged and removed.
+func printChanges(res *crawler.CrawlResult) {
+	counts := make(map[string]int)
+	for _, p := range res.Pages {
+		counts[p.Change]++
+	}
+	fmt.Printf("Changes: %d added, %d changed, %d unchanged, %d removed\n",
+		counts[crawler.ChangeAdded], counts[crawler.ChangeChanged],
+		counts[crawler.ChangeUnchanged], counts[crawler.ChangeRemoved])
+}
+
+// addCrawlResult records the outcome of a crawl run in step, one entry per
+// URL in URL order.
+func addCrawlResult(step *report.StepResult, res *crawler.CrawlResult) {
+	if res == nil {
+		return
+	}
+	urls := make([]string, 0, len(res.Pages))
+	for u := range res.Pages {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	for _, u := range urls {
+		page := res.Pages[u]
+		st := report.URLStatus{
+			URL:         u,
+			Path:        page.Path,
+			Depth:       page.Depth,
+			Attempts:    page.Attempts,
+			ErrorClass:  page.ErrorClass,
+			ContentType: page.ContentType,
+			Canonical:   page.Canonical,
+			RedirectTo:  page.RedirectTo,
+			Change:      page.Change,
+			Seed:        page.Seed,
+		}
+		switch {
+		case res.Errors[u] != "":
+			st.Status = report.StatusError
+			st.Error = res.Errors[u]
+		case res.Skipped[u] != "":
+			st.Status = report.StatusSkipped
+			st.Reason = res.Skipped[u]
+		default:
+			st.Status = report.StatusOK
+		}
+		step.URLs = append(step.URLs, st)
+	}
+}
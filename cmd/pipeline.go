@@ -15,38 +15,107 @@ import (
 )
 
 var pipelineCmd = &cobra.Command{
-	Use:   "pipeline <url>",
+	Use:   "pipeline <url>...",
 	Short: "Run crawl → convert → combine in sequence",
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.ArbitraryArgs,
 	RunE:  runPipeline,
 }
 
 var (
 	pipelineOutput      string
+	pipelineSeedsFile   string
+	pipelineScope       string
+	pipelineAllowHosts  []string
 	pipelineMaxPages    int
+	pipelineOrder       string
+	pipelineWeights     []string
+	pipelineMaxDepth    int
 	pipelineDelay       time.Duration
 	pipelineConcurrency int
+	pipelineMaxPerHost  int
+	pipelineHostLimits  []string
+	pipelineAdaptive    bool
+	pipelineMaxRetries  int
+	pipelineBackoff     time.Duration
 	pipelineWorkers     int
 	pipelineStripTags   string
 	pipelineStripCls    string
 	pipelineMaxWords    int
+	pipelineFollowRels  bool
+	pipelineIgnoreRobot bool
+	pipelineUseSitemaps bool
+	pipelineSitemaps    []string
+	pipelineSitemapOnly bool
+	pipelineInclude     []string
+	pipelineExclude     []string
+	pipelineAllowTypes  []string
+	pipelineDropParams  []string
+	pipelineSortQuery   bool
+	pipelineIgnoreQuery bool
+	pipelineHeaders     []string
+	pipelineBearer      string
+	pipelineBasicAuth   string
+	pipelineCookies     string
+	pipelineTimeout     time.Duration
+	pipelineProxy       string
+	pipelineCACerts     []string
+	pipelineClientCert  string
+	pipelineClientKey   string
+	pipelineInsecure    bool
+	pipelineMaxBody     string
 )
 
 func init() {
 	rootCmd.AddCommand(pipelineCmd)
 
 	pipelineCmd.Flags().StringVarP(&pipelineOutput, "output", "o", "pipeline_output", "base output directory")
+	pipelineCmd.Flags().StringVar(&pipelineSeedsFile, "seeds-file", "", "file with additional seed URLs, one per line")
+	pipelineCmd.Flags().StringVar(&pipelineScope, "scope", "", "link scope: host (seed host only), domain (all subdomains) or hosts (seeds plus --allow-host)")
+	pipelineCmd.Flags().StringArrayVar(&pipelineAllowHosts, "allow-host", nil, "extra host[/path-prefix] to crawl, e.g. api.example.com/v2 (repeatable, implies --scope hosts)")
 	pipelineCmd.Flags().IntVar(&pipelineMaxPages, "max-pages", 0, "maximum pages to crawl")
+	pipelineCmd.Flags().IntVar(&pipelineMaxDepth, "max-depth", 0, "maximum link depth from the seed URL")
+	pipelineCmd.Flags().StringVar(&pipelineOrder, "order", "", "crawl order: discovery, depth or priority (default discovery, or priority with --weight)")
+	pipelineCmd.Flags().StringArrayVar(&pipelineWeights, "weight", nil, `URL priority weight "pattern=weight", e.g. "/guide/*=2" or "*/release-notes/*=-1" (repeatable, implies --order priority)`)
 	pipelineCmd.Flags().DurationVar(&pipelineDelay, "delay", time.Second, "delay between crawl requests")
 	pipelineCmd.Flags().IntVar(&pipelineConcurrency, "max-concurrency", 5, "parallel crawl workers")
+	pipelineCmd.Flags().IntVar(&pipelineMaxPerHost, "max-per-host", 0, "maximum parallel crawl requests to one host (0 = unlimited)")
+	pipelineCmd.Flags().StringArrayVar(&pipelineHostLimits, "host-limit", nil, `per-host override "pattern=delay[,max-in-flight]" (repeatable)`)
+	pipelineCmd.Flags().BoolVar(&pipelineAdaptive, "adaptive-delay", false, "slow down hosts whose latency or error rate rises")
+	pipelineCmd.Flags().IntVar(&pipelineMaxRetries, "max-retries", 3, "retries for transient crawl failures")
+	pipelineCmd.Flags().DurationVar(&pipelineBackoff, "retry-backoff", time.Second, "base delay for exponential retry backoff")
 	pipelineCmd.Flags().IntVar(&pipelineWorkers, "max-workers", 4, "parallel convert workers")
 	pipelineCmd.Flags().StringVar(&pipelineStripTags, "strip-tags", "", "HTML tags to strip during convert")
 	pipelineCmd.Flags().StringVar(&pipelineStripCls, "strip-classes", "", "CSS classes to strip during convert")
 	pipelineCmd.Flags().IntVar(&pipelineMaxWords, "max-words", 500_000, "max words per combined output file")
+	pipelineCmd.Flags().BoolVar(&pipelineIgnoreRobot, "ignore-robots", false, "do not obey robots.txt, meta robots or rel=nofollow (only for sites you own)")
+	pipelineCmd.Flags().BoolVar(&pipelineFollowRels, "follow-link-rel", false, "also follow <link rel> next, prev and alternate hreflang links")
+	pipelineCmd.Flags().BoolVar(&pipelineUseSitemaps, "use-sitemaps", false, "seed the crawl from sitemaps listed in robots.txt (or /sitemap.xml)")
+	pipelineCmd.Flags().StringArrayVar(&pipelineSitemaps, "sitemap", nil, "explicit sitemap URL to seed from (repeatable)")
+	pipelineCmd.Flags().BoolVar(&pipelineSitemapOnly, "sitemap-only", false, "crawl only sitemap URLs without following links")
+	pipelineCmd.Flags().StringArrayVar(&pipelineInclude, "include", nil, "only crawl URLs matching this glob (or re:<regexp>) (repeatable)")
+	pipelineCmd.Flags().StringArrayVar(&pipelineExclude, "exclude", nil, "skip URLs matching this glob (or re:<regexp>) (repeatable)")
+	pipelineCmd.Flags().StringSliceVar(&pipelineDropParams, "drop-param", crawler.DefaultTrackingParams, "query parameters to strip from URLs; trailing * matches a prefix (repeatable)")
+	pipelineCmd.Flags().BoolVar(&pipelineSortQuery, "sort-query", false, "sort query parameters")
+	pipelineCmd.Flags().BoolVar(&pipelineIgnoreQuery, "ignore-query", false, "drop query strings from URLs entirely")
+	pipelineCmd.Flags().StringSliceVar(&pipelineAllowTypes, "allow-type", nil, "non-HTML media types to save under html/_files (repeatable)")
+	pipelineCmd.Flags().StringArrayVarP(&pipelineHeaders, "header", "H", nil, `extra request header "Name: value" sent to in-scope hosts (repeatable)`)
+	pipelineCmd.Flags().StringVar(&pipelineBearer, "bearer-token", "", "bearer token sent to in-scope hosts")
+	pipelineCmd.Flags().StringVar(&pipelineBasicAuth, "basic-auth", "", `HTTP basic auth "user:password" for in-scope hosts`)
+	pipelineCmd.Flags().StringVar(&pipelineCookies, "cookies", "", "Netscape cookies.txt file whose cookies are sent to in-scope hosts")
+	pipelineCmd.Flags().DurationVar(&pipelineTimeout, "timeout", 30*time.Second, "timeout for a single HTTP request")
+	pipelineCmd.Flags().StringVar(&pipelineProxy, "proxy", "", "HTTP(S) proxy URL (default from HTTP_PROXY/HTTPS_PROXY)")
+	pipelineCmd.Flags().StringArrayVar(&pipelineCACerts, "ca-cert", nil, "extra PEM CA certificate file to trust (repeatable)")
+	pipelineCmd.Flags().StringVar(&pipelineClientCert, "client-cert", "", "PEM client certificate for mutual TLS")
+	pipelineCmd.Flags().StringVar(&pipelineClientKey, "client-key", "", "PEM private key for --client-cert")
+	pipelineCmd.Flags().BoolVar(&pipelineInsecure, "insecure-skip-verify", false, "do not verify TLS certificates (testing only)")
+	pipelineCmd.Flags().StringVar(&pipelineMaxBody, "max-body-size", "0", "largest response to download, e.g. 50MB (0 = unlimited)")
 }
 
 func runPipeline(cmd *cobra.Command, args []string) error {
-	startURL := args[0]
+	seeds, err := loadSeeds(args, pipelineSeedsFile)
+	if err != nil {
+		return err
+	}
 	htmlDir := filepath.Join(pipelineOutput, "html")
 	mdDir := filepath.Join(pipelineOutput, "md")
 	combinedPath := filepath.Join(pipelineOutput, "combined.md")
@@ -57,13 +126,56 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	}
 
 	// --- Crawl ---
-	slog.Info("pipeline: starting crawl", "url", startURL)
+	slog.Info("pipeline: starting crawl", "seeds", seeds)
 	crawlCfg := crawler.CrawlConfig{
-		StartURL:       startURL,
-		OutputDir:      htmlDir,
-		MaxPages:       pipelineMaxPages,
-		Delay:          pipelineDelay,
-		MaxConcurrency: pipelineConcurrency,
+		StartURL:           seeds[0],
+		Seeds:              seeds[1:],
+		OutputDir:          htmlDir,
+		ScopeMode:          crawler.ScopeMode(pipelineScope),
+		AllowedHosts:       pipelineAllowHosts,
+		MaxPages:           pipelineMaxPages,
+		MaxDepth:           pipelineMaxDepth,
+		Order:              crawler.FrontierOrder(pipelineOrder),
+		Delay:              pipelineDelay,
+		MaxConcurrency:     pipelineConcurrency,
+		MaxPerHost:         pipelineMaxPerHost,
+		AdaptiveDelay:      pipelineAdaptive,
+		MaxRetries:         pipelineMaxRetries,
+		RetryBackoff:       pipelineBackoff,
+		IgnoreRobots:       pipelineIgnoreRobot,
+		FollowLinkRels:     pipelineFollowRels,
+		UseSitemaps:        pipelineUseSitemaps,
+		SitemapURLs:        pipelineSitemaps,
+		SitemapOnly:        pipelineSitemapOnly,
+		Include:            pipelineInclude,
+		Exclude:            pipelineExclude,
+		AllowTypes:         pipelineAllowTypes,
+		Timeout:            pipelineTimeout,
+		Proxy:              pipelineProxy,
+		CACertFiles:        pipelineCACerts,
+		ClientCert:         pipelineClientCert,
+		ClientKey:          pipelineClientKey,
+		InsecureSkipVerify: pipelineInsecure,
+		Normalize: crawler.NormalizeOptions{
+			DropParams:  pipelineDropParams,
+			SortQuery:   pipelineSortQuery,
+			IgnoreQuery: pipelineIgnoreQuery,
+		},
+	}
+	maxBody, err := parseByteSize(pipelineMaxBody)
+	if err != nil {
+		return fmt.Errorf("invalid --max-body-size: %w", err)
+	}
+	crawlCfg.MaxBodySize = maxBody
+	if crawlCfg.HostLimits, err = parseHostLimits(pipelineHostLimits); err != nil {
+		return err
+	}
+	if crawlCfg.URLWeights, err = parseURLWeights(pipelineWeights); err != nil {
+		return err
+	}
+
+	if err := setCrawlAuth(&crawlCfg, pipelineHeaders, pipelineBearer, pipelineBasicAuth, pipelineCookies); err != nil {
+		return err
 	}
 	var crawlStep *report.StepResult
 	if rep != nil {
@@ -71,18 +183,13 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	}
 	crawlRes, err := crawler.Run(cmd.Context(), crawlCfg)
 	if crawlStep != nil {
-		for _, s := range crawlRes.Saved {
-			crawlStep.URLs = append(crawlStep.URLs, report.URLStatus{URL: s, Status: report.StatusOK})
-		}
-		for u, e := range crawlRes.Errors {
-			crawlStep.URLs = append(crawlStep.URLs, report.URLStatus{URL: u, Status: report.StatusError, Error: e})
-		}
+		addCrawlResult(crawlStep, crawlRes)
 		report.Finish(crawlStep)
 	}
 	if err != nil {
 		return fmt.Errorf("crawl: %w", err)
 	}
-	slog.Info("pipeline: crawl done", "saved", len(crawlRes.Saved), "errors", len(crawlRes.Errors))
+	slog.Info("pipeline: crawl done", "saved", len(crawlRes.Saved), "skipped", len(crawlRes.Skipped), "errors", len(crawlRes.Errors))
 
 	// --- Convert ---
 	slog.Info("pipeline: starting convert")
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -28,9 +31,12 @@ and combines them into a single document suitable for NotebookLM.`,
 	},
 }
 
-// Execute runs the root command.
+// Execute runs the root command. Interrupt and termination signals cancel
+// the command's context so long-running steps can stop cleanly.
 func Execute() error {
-	return rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return rootCmd.ExecuteContext(ctx)
 }
 
 func init() {
@@ -0,0 +1,92 @@
+// Package charset detects the character encoding of HTML documents and
+// transcodes them to UTF-8.
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	htmlcharset "golang.org/x/net/html/charset"
+)
+
+// UTF8 is the name Detect returns for UTF-8 documents.
+const UTF8 = "utf-8"
+
+// sniffCandidates are tried, in order, for undeclared documents that are not
+// valid UTF-8.
+var sniffCandidates = []string{"shift_jis", "euc-jp", "gbk", "euc-kr"}
+
+// Detect returns the WHATWG name of the encoding of an HTML document, using
+// in order: a byte order mark, the charset parameter of contentType, a
+// <meta charset> or http-equiv declaration in the first 1024 bytes, and
+// finally sniffing the content. A header that claims UTF-8 for a body that
+// is not valid UTF-8 is ignored, as misconfigured servers often do this.
+func Detect(body []byte, contentType string) string {
+	_, name, certain := htmlcharset.DetermineEncoding(body, contentType)
+	if name == UTF8 && !utf8.Valid(body) && contentType != "" {
+		_, name, certain = htmlcharset.DetermineEncoding(body, "")
+	}
+	if certain || name != "windows-1252" {
+		return name
+	}
+	// Nothing was declared (DetermineEncoding falls back to windows-1252
+	// after looking at only the first 1024 bytes): sniff the whole body.
+	return sniff(body)
+}
+
+// sniff guesses the encoding of an undeclared document.
+func sniff(body []byte) string {
+	if bytes.Contains(body, []byte("\x1b$B")) || bytes.Contains(body, []byte("\x1b$@")) {
+		return "iso-2022-jp"
+	}
+	if utf8.Valid(body) {
+		return UTF8
+	}
+	for _, name := range sniffCandidates {
+		if decodesCleanly(body, name) {
+			return name
+		}
+	}
+	return "windows-1252"
+}
+
+// decodesCleanly reports whether body decodes in the named encoding without
+// any replacement characters.
+func decodesCleanly(body []byte, name string) bool {
+	enc, _ := htmlcharset.Lookup(name)
+	if enc == nil {
+		return false
+	}
+	out, err := enc.NewDecoder().Bytes(body)
+	return err == nil && !bytes.ContainsRune(out, utf8.RuneError)
+}
+
+// metaCharset matches the charset value of a <meta charset> or
+// <meta http-equiv="Content-Type"> declaration.
+var metaCharset = regexp.MustCompile(`(?i)(<meta\b[^>]*?charset\s*=\s*["']?)[\w:.-]+`)
+
+// ToUTF8 detects the encoding of an HTML document (see Detect) and returns
+// it transcoded to UTF-8, along with the detected encoding name. A leading
+// byte order mark is removed and charset declarations in <meta> tags are
+// rewritten to utf-8 so the result is self-consistent.
+func ToUTF8(body []byte, contentType string) ([]byte, string, error) {
+	name := Detect(body, contentType)
+	out := body
+	if name == UTF8 {
+		out = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	} else {
+		enc, _ := htmlcharset.Lookup(name)
+		if enc == nil {
+			return body, name, fmt.Errorf("charset: unsupported encoding %q", name)
+		}
+		var err error
+		// The decoder handles and strips a UTF-16 byte order mark itself.
+		out, err = enc.NewDecoder().Bytes(body)
+		if err != nil {
+			return body, name, fmt.Errorf("charset: decode %s: %w", name, err)
+		}
+	}
+	return metaCharset.ReplaceAll(out, []byte("${1}utf-8")), name, nil
+}
@@ -0,0 +1,71 @@
+package charset
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const japaneseText = "日本語のドキュメントです。"
+
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return b
+}
+
+func TestDetect(t *testing.T) {
+	page := "<html><body><p>" + japaneseText + "</p></body></html>"
+	sjis := encode(t, japanese.ShiftJIS, page)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"header", sjis, "text/html; charset=Shift_JIS", "shift_jis"},
+		{"header claims utf-8", sjis, "text/html; charset=utf-8", "shift_jis"},
+		{"meta charset", encode(t, japanese.EUCJP, `<meta charset="euc-jp">`+page), "text/html", "euc-jp"},
+		{"http-equiv", encode(t, japanese.ShiftJIS, `<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">`+page), "", "shift_jis"},
+		{"bom", append([]byte("\xef\xbb\xbf"), page...), "text/html; charset=shift_jis", "utf-8"},
+		{"utf-16 bom", encode(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), page), "", "utf-16le"},
+		{"sniff utf-8", []byte(page), "", "utf-8"},
+		{"sniff shift_jis", sjis, "text/html", "shift_jis"},
+		{"sniff euc-jp", encode(t, japanese.EUCJP, page), "", "euc-jp"},
+		{"sniff iso-2022-jp", encode(t, japanese.ISO2022JP, page), "", "iso-2022-jp"},
+		{"sniff past 1024 bytes", encode(t, japanese.ShiftJIS, strings.Repeat(" ", 2000)+page), "", "shift_jis"},
+		{"ascii", []byte("<p>hello</p>"), "", "utf-8"},
+	}
+	for _, tc := range tests {
+		if got := Detect(tc.body, tc.contentType); got != tc.want {
+			t.Errorf("%s: Detect = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	src := `<html><head><meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS"></head><body>` + japaneseText + `</body></html>`
+	out, name, err := ToUTF8(encode(t, japanese.ShiftJIS, src), "")
+	if err != nil {
+		t.Fatalf("ToUTF8: %v", err)
+	}
+	if name != "shift_jis" {
+		t.Errorf("name = %q, want shift_jis", name)
+	}
+	want := strings.Replace(src, "charset=Shift_JIS", "charset=utf-8", 1)
+	if string(out) != want {
+		t.Errorf("ToUTF8 =\n%s\nwant\n%s", out, want)
+	}
+
+	out, name, err = ToUTF8([]byte("\xef\xbb\xbf<meta charset='utf-8'>ok"), "")
+	if err != nil || name != "utf-8" || string(out) != "<meta charset='utf-8'>ok" {
+		t.Errorf("ToUTF8(bom) = %q, %q, %v", out, name, err)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"golm-connector/internal/manifest"
 )
 
 const defaultMaxWords = 500_000
@@ -39,6 +41,11 @@ func Run(cfg CombineConfig) (*CombineResult, error) {
 		return &CombineResult{}, nil
 	}
 
+	pages, err := manifest.LoadDir(cfg.InputDir)
+	if err != nil {
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
+
 	// Ensure output directory exists.
 	outDir := filepath.Dir(cfg.OutputPath)
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
@@ -86,6 +93,9 @@ func Run(cfg CombineConfig) (*CombineResult, error) {
 			continue
 		}
 		content := string(data)
+		if src := sourceLine(pages, cfg.InputDir, f); src != "" {
+			content = src + "\n\n" + content
+		}
 		w := len(strings.Fields(content))
 		entries = append(entries, entry{path: f, content: content, words: w})
 		totalWords += w
@@ -135,6 +145,35 @@ func Run(cfg CombineConfig) (*CombineResult, error) {
 	return res, nil
 }
 
+// sourceLine returns a "Source:" line naming the page a Markdown file was
+// converted from (its final URL after redirects), or "" when the manifest
+// has no entry for it.
+func sourceLine(pages map[string]manifest.Entry, inputDir, mdPath string) string {
+	if pages == nil {
+		return ""
+	}
+	rel, err := filepath.Rel(inputDir, mdPath)
+	if err != nil {
+		return ""
+	}
+	e, ok := pages[filepath.ToSlash(rel)]
+	if !ok {
+		return ""
+	}
+	src := e.FinalURL
+	if src == "" {
+		src = e.URL
+	}
+	if src == "" {
+		return ""
+	}
+	if e.Title == "" {
+		return fmt.Sprintf("Source: <%s>", src)
+	}
+	title := strings.NewReplacer("[", `\[`, "]", `\]`).Replace(e.Title)
+	return fmt.Sprintf("Source: [%s](%s)", title, src)
+}
+
 // numberedPath inserts a zero-padded part number before the file extension.
 // e.g. combined.md + 2 → combined-002.md
 func numberedPath(base string, n int) string {
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"golm-connector/internal/manifest"
 )
 
 func TestCombineBasic(t *testing.T) {
@@ -108,6 +110,40 @@ func TestCombineEmpty(t *testing.T) {
 	}
 }
 
+func TestCombineManifestSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.md"), "Content of A.")
+	writeFile(t, filepath.Join(dir, "b.md"), "Content of B.")
+	writeFile(t, filepath.Join(dir, "c.md"), "Content of C.")
+	err := manifest.Write(filepath.Join(dir, manifest.FileName), []manifest.Entry{
+		{URL: "https://example.com/a", Path: "a.md", Title: "Page A"},
+		{URL: "https://example.com/b", Path: "b.md"},
+		{URL: "https://example.com/old", FinalURL: "https://example.com/c", Path: "c.md", Title: "Page C"},
+	})
+	if err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	outPath := filepath.Join(t.TempDir(), "combined.md")
+	if _, err := Run(CombineConfig{InputDir: dir, OutputPath: outPath}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{
+		"Source: [Page A](https://example.com/a)\n\nContent of A.",
+		"Source: <https://example.com/b>\n\nContent of B.",
+		"Source: [Page C](https://example.com/c)\n\nContent of C.",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("output missing %q:\n%s", want, content)
+		}
+	}
+}
+
 func TestNumberedPath(t *testing.T) {
 	tests := []struct {
 		base string
@@ -8,6 +8,9 @@ type ConvertConfig struct {
 	OutputDir string
 	// ZipPath is an optional ZIP archive to read HTML from (overrides InputDir).
 	ZipPath string
+	// WARCPaths are optional WARC files whose HTML responses are converted
+	// (overrides InputDir). Each page's target URI becomes its source URL.
+	WARCPaths []string
 	// Workers is the number of parallel conversion goroutines.
 	Workers int
 	// StripTags is a list of HTML tag names whose elements should be removed.
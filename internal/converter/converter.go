@@ -6,15 +6,23 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+
+	"golm-connector/internal/charset"
+	"golm-connector/internal/manifest"
 )
 
-// Run converts HTML files in cfg.InputDir (or cfg.ZipPath) to Markdown files
-// in cfg.OutputDir, using a bounded worker pool.
+// Run converts HTML files in cfg.InputDir (or cfg.ZipPath, or cfg.WARCPaths)
+// to Markdown files in cfg.OutputDir, using a bounded worker pool.
 func Run(cfg ConvertConfig) (*ConvertResult, error) {
 	inputDir := cfg.InputDir
 
+	if cfg.ZipPath != "" && len(cfg.WARCPaths) > 0 {
+		return nil, fmt.Errorf("zip and warc inputs cannot be combined")
+	}
+
 	// If a ZIP was provided, extract it to a temp dir first.
 	if cfg.ZipPath != "" {
 		tmp, err := os.MkdirTemp("", "golm-zip-*")
@@ -29,6 +37,21 @@ func Run(cfg ConvertConfig) (*ConvertResult, error) {
 		inputDir = tmp
 	}
 
+	// WARC records are extracted the same way, along with a manifest of
+	// their URLs.
+	if len(cfg.WARCPaths) > 0 {
+		tmp, err := os.MkdirTemp("", "golm-warc-*")
+		if err != nil {
+			return nil, fmt.Errorf("temp dir for warc: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		if _, err := ExtractWARC(cfg.WARCPaths, tmp); err != nil {
+			return nil, fmt.Errorf("extract warc: %w", err)
+		}
+		inputDir = tmp
+	}
+
 	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
 		return nil, fmt.Errorf("mkdir output: %w", err)
 	}
@@ -48,6 +71,11 @@ func Run(cfg ConvertConfig) (*ConvertResult, error) {
 		return nil, fmt.Errorf("walk input dir: %w", err)
 	}
 
+	pages, err := manifest.LoadDir(inputDir)
+	if err != nil {
+		return nil, fmt.Errorf("load manifest: %w", err)
+	}
+
 	workers := cfg.Workers
 	if workers <= 0 {
 		workers = 4
@@ -88,6 +116,7 @@ func Run(cfg ConvertConfig) (*ConvertResult, error) {
 	}()
 
 	res := &ConvertResult{Errors: make(map[string]string)}
+	var converted []manifest.Entry
 	done := 0
 	for r := range results {
 		if r.err != nil {
@@ -96,14 +125,44 @@ func Run(cfg ConvertConfig) (*ConvertResult, error) {
 		} else {
 			done++
 			res.Saved = append(res.Saved, r.out)
+			if e, ok := pageFor(pages, inputDir, r.path); ok {
+				e.Path = manifestPath(cfg.OutputDir, r.out)
+				converted = append(converted, e)
+			}
 			slog.Info("convert: done", "n", done, "total", len(htmlFiles), "file", filepath.Base(r.out))
 			slog.Debug("convert: done path", "file", r.path, "out", r.out)
 		}
 	}
 
+	if pages != nil {
+		sort.Slice(converted, func(i, j int) bool { return converted[i].Path < converted[j].Path })
+		if err := manifest.Write(filepath.Join(cfg.OutputDir, manifest.FileName), converted); err != nil {
+			return res, err
+		}
+	}
+
 	return res, nil
 }
 
+// pageFor looks up the crawl manifest entry for an input HTML file.
+func pageFor(pages map[string]manifest.Entry, inputDir, htmlPath string) (manifest.Entry, bool) {
+	if pages == nil {
+		return manifest.Entry{}, false
+	}
+	e, ok := pages[manifestPath(inputDir, htmlPath)]
+	return e, ok
+}
+
+// manifestPath returns p relative to dir with forward slashes, as stored in
+// a manifest.
+func manifestPath(dir, p string) string {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		rel = p
+	}
+	return filepath.ToSlash(rel)
+}
+
 // convertFile reads an HTML file, converts it to Markdown, and writes the output.
 func convertFile(htmlPath, inputDir, outputDir string, cfg *ConvertConfig) (string, error) {
 	data, err := os.ReadFile(htmlPath)
@@ -111,6 +170,11 @@ func convertFile(htmlPath, inputDir, outputDir string, cfg *ConvertConfig) (stri
 		return "", fmt.Errorf("read %s: %w", htmlPath, err)
 	}
 
+	// Crawled pages are already UTF-8; other inputs may not be.
+	if data, _, err = charset.ToUTF8(data, ""); err != nil {
+		return "", fmt.Errorf("decode %s: %w", htmlPath, err)
+	}
+
 	node, err := ExtractMainNode(data)
 	if err != nil {
 		return "", fmt.Errorf("extract %s: %w", htmlPath, err)
@@ -0,0 +1,189 @@
+package converter
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golm-connector/internal/charset"
+	"golm-connector/internal/crawler"
+	"golm-connector/internal/manifest"
+	"golm-connector/internal/warc"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractWARC extracts the HTML pages archived in the WARC files at paths
+// into destDir, transcoded to UTF-8 and named after their target URI as
+// crawl would save them, and writes a manifest mapping each file to that
+// URI. Non-HTML records and unsuccessful responses are skipped; when a URI
+// was captured more than once, the latest capture wins.
+// Returns the list of extracted file paths.
+func ExtractWARC(paths []string, destDir string) ([]string, error) {
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", destDir, err)
+	}
+
+	pages := make(map[string]manifest.Entry)
+	for _, p := range paths {
+		if err := extractWARCFile(p, destDir, pages); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]manifest.Entry, 0, len(pages))
+	extracted := make([]string, 0, len(pages))
+	for _, e := range pages {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	for _, e := range entries {
+		extracted = append(extracted, filepath.Join(destDir, filepath.FromSlash(e.Path)))
+	}
+	if err := manifest.Write(filepath.Join(destDir, manifest.FileName), entries); err != nil {
+		return nil, err
+	}
+	return extracted, nil
+}
+
+// extractWARCFile extracts the HTML pages of one WARC file, recording them
+// in pages by manifest path.
+func extractWARCFile(path, destDir string, pages map[string]manifest.Entry) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open warc %s: %w", path, err)
+	}
+	defer f.Close()
+
+	r, err := warc.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("read warc %s: %w", path, err)
+	}
+	for {
+		h, block, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read warc %s: %w", path, err)
+		}
+
+		page, err := readWARCPage(h, block)
+		if err != nil {
+			slog.Warn("convert: skipped WARC record", "url", h.TargetURI, "err", err)
+			continue
+		}
+		if page == nil {
+			continue
+		}
+		rel := crawler.URLToFilename(crawler.Normalize(h.TargetURI))
+		if rel == "" {
+			slog.Warn("convert: skipped WARC record", "url", h.TargetURI, "err", "cannot derive filename")
+			continue
+		}
+		if prev, ok := pages[rel]; ok && h.Date.Before(prev.FetchedAt) {
+			continue
+		}
+
+		data, name, err := charset.ToUTF8(page.body, page.contentType)
+		if err != nil {
+			slog.Warn("convert: transcode failed", "url", h.TargetURI, "charset", name, "err", err)
+		}
+		outPath := filepath.Join(destDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+			return fmt.Errorf("mkdir for %s: %w", outPath, err)
+		}
+		if err := os.WriteFile(outPath, data, 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", outPath, err)
+		}
+
+		sum := sha256.Sum256(page.body)
+		e := manifest.Entry{
+			URL:         h.TargetURI,
+			Path:        rel,
+			Status:      page.status,
+			ContentType: page.mediaType,
+			Size:        int64(len(page.body)),
+			Hash:        hex.EncodeToString(sum[:]),
+			FetchedAt:   h.Date,
+			Charset:     name,
+		}
+		if doc, err := html.Parse(bytes.NewReader(data)); err == nil {
+			e.Title = crawler.HTMLTitle(doc)
+		}
+		pages[rel] = e
+	}
+}
+
+// warcPage is an HTML page read from a WARC record.
+type warcPage struct {
+	status      int
+	contentType string
+	mediaType   string
+	body        []byte
+}
+
+// readWARCPage returns the HTML page archived in a response or resource
+// record, or nil when the record holds anything else.
+func readWARCPage(h *warc.Header, block io.Reader) (*warcPage, error) {
+	page := &warcPage{status: http.StatusOK}
+	var body io.Reader
+	switch h.Type {
+	case warc.TypeResponse:
+		if !strings.HasPrefix(h.TargetURI, "http://") && !strings.HasPrefix(h.TargetURI, "https://") {
+			return nil, nil
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(block), nil)
+		if err != nil {
+			return nil, fmt.Errorf("parse HTTP response: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return nil, nil
+		}
+		page.status = resp.StatusCode
+		page.contentType = resp.Header.Get("Content-Type")
+		body = resp.Body
+		switch enc := strings.ToLower(resp.Header.Get("Content-Encoding")); enc {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				return nil, fmt.Errorf("gunzip body: %w", err)
+			}
+			body = zr
+		default:
+			return nil, fmt.Errorf("unsupported Content-Encoding %q", enc)
+		}
+	case warc.TypeResource:
+		page.contentType = h.ContentType
+		body = block
+	default:
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	page.body = data
+	page.mediaType, _, _ = mime.ParseMediaType(page.contentType)
+	if page.mediaType == "" {
+		page.mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	if page.mediaType != "text/html" && page.mediaType != "application/xhtml+xml" {
+		return nil, nil
+	}
+	return page, nil
+}
@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golm-connector/internal/manifest"
+	"golm-connector/internal/warc"
+
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestExtractWARC(t *testing.T) {
+	sjis, err := japanese.ShiftJIS.NewEncoder().String("<html><title>日本語</title><p>本文</p></html>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := warc.NewWriter(warc.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	response := func(uri string, date time.Time, head, body string) warc.Record {
+		return warc.Record{
+			Type:        warc.TypeResponse,
+			TargetURI:   uri,
+			Date:        date,
+			ContentType: warc.ContentTypeResponse,
+			HTTPHeader:  []byte(head + "\r\n"),
+			Payload:     strings.NewReader(body),
+		}
+	}
+	err = w.Write(
+		response("https://example.com/docs/ja", newer, "HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=Shift_JIS\r\n", sjis),
+		response("https://example.com/docs/a", newer, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n", "<html>new</html>"),
+		response("https://example.com/docs/a", older, "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n", "<html>old</html>"),
+		response("https://example.com/docs/missing", newer, "HTTP/1.1 404 Not Found\r\nContent-Type: text/html\r\n", "<html>404</html>"),
+		response("https://example.com/logo.png", newer, "HTTP/1.1 200 OK\r\nContent-Type: image/png\r\n", "\x89PNG"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	files, err := ExtractWARC(w.Files(), dest)
+	if err != nil {
+		t.Fatalf("ExtractWARC: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("extracted %v, want 2 pages", files)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "example.com", "docs", "a.html")); string(data) != "<html>new</html>" {
+		t.Errorf("a.html = %q, want the latest capture", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "example.com", "docs", "ja.html")); !strings.Contains(string(data), "本文") {
+		t.Errorf("ja.html = %q, want transcoded UTF-8", data)
+	}
+
+	pages, err := manifest.LoadDir(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ja := pages["example.com/docs/ja.html"]
+	if ja.URL != "https://example.com/docs/ja" || ja.Charset != "shift_jis" || ja.Title != "日本語" {
+		t.Errorf("manifest entry = %+v", ja)
+	}
+}
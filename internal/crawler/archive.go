@@ -0,0 +1,155 @@
+package crawler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golm-connector/internal/warc"
+)
+
+// recordedRequestHeaders are the request headers the crawler itself sets,
+// the only ones copied into WARC request records: configured headers,
+// credentials and cookies may carry secrets.
+var recordedRequestHeaders = map[string]bool{
+	"User-Agent":        true,
+	"If-None-Match":     true,
+	"If-Modified-Since": true,
+}
+
+// archiveResponse writes resp to w as a request record (when a request was
+// sent), a response record and a metadata record describing how the crawl
+// reached it. Responses of non-HTTP fetchers become resource records.
+func archiveResponse(w *warc.Writer, resp *Response, job queueItem) error {
+	payload, err := responsePayload(resp)
+	if err != nil {
+		return err
+	}
+	if c, ok := payload.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	target := resp.FinalURL
+	if target == "" {
+		target = resp.URL
+	}
+	respID := warc.NewRecordID()
+	var recs []warc.Record
+
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		recs = append(recs, warc.Record{
+			ID:          respID,
+			Type:        warc.TypeResource,
+			TargetURI:   target,
+			Date:        resp.FetchedAt,
+			ContentType: resp.Header.Get("Content-Type"),
+			Payload:     payload,
+		})
+	} else {
+		if resp.RequestHeader != nil {
+			if head, err := requestHead(target, resp.RequestHeader); err == nil {
+				recs = append(recs, warc.Record{
+					Type:        warc.TypeRequest,
+					TargetURI:   target,
+					Date:        resp.FetchedAt,
+					ContentType: warc.ContentTypeRequest,
+					Fields:      map[string]string{"WARC-Concurrent-To": respID},
+					HTTPHeader:  head,
+				})
+			}
+		}
+		recs = append(recs, warc.Record{
+			ID:          respID,
+			Type:        warc.TypeResponse,
+			TargetURI:   target,
+			Date:        resp.FetchedAt,
+			ContentType: warc.ContentTypeResponse,
+			HTTPHeader:  responseHead(resp),
+			Payload:     payload,
+		})
+	}
+
+	var meta bytes.Buffer
+	metaField := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&meta, "%s: %s\r\n", name, value)
+		}
+	}
+	if resp.URL != target {
+		metaField("requestedURL", resp.URL)
+	}
+	metaField("via", job.Referrer)
+	metaField("depth", strconv.Itoa(job.Depth))
+	metaField("attempts", strconv.Itoa(resp.Attempts))
+	metaField("fromCache", strconv.FormatBool(resp.FromCache))
+	recs = append(recs, warc.Record{
+		Type:        warc.TypeMetadata,
+		TargetURI:   target,
+		Date:        resp.FetchedAt,
+		ContentType: warc.ContentTypeFields,
+		Fields:      map[string]string{"WARC-Refers-To": respID},
+		Payload:     bytes.NewReader(meta.Bytes()),
+	})
+
+	if err := w.Write(recs...); err != nil {
+		return fmt.Errorf("archive %s: %w", resp.URL, err)
+	}
+	return nil
+}
+
+// responsePayload opens the body of resp for reading, from memory or from
+// its spooled file.
+func responsePayload(resp *Response) (io.ReadSeeker, error) {
+	if resp.BodyFile == "" {
+		return bytes.NewReader(resp.Body), nil
+	}
+	f, err := os.Open(resp.BodyFile)
+	if err != nil {
+		return nil, fmt.Errorf("archive %s: %w", resp.URL, err)
+	}
+	return f, nil
+}
+
+// requestHead renders the GET request line and the crawler's own headers
+// sent for target.
+func requestHead(target string, header http.Header) ([]byte, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	h := make(http.Header, len(header)+1)
+	for name, values := range header {
+		if recordedRequestHeaders[name] {
+			h[name] = values
+		}
+	}
+	h.Set("Host", u.Host)
+	if h.Get("User-Agent") == "" {
+		h.Set("User-Agent", userAgent)
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "GET %s HTTP/1.1\r\n", u.RequestURI())
+	_ = h.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes(), nil
+}
+
+// responseHead renders the status line and headers of resp, without
+// Set-Cookie.
+func responseHead(resp *Response) []byte {
+	proto := resp.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %d %s\r\n", proto, resp.StatusCode, http.StatusText(resp.StatusCode))
+	_ = withoutCookies(resp.Header).Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
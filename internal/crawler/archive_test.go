@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunWARC(t *testing.T) {
+	var apiKey string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-Api-Key")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><a href="/a">a</a></html>`)
+			return
+		}
+		fmt.Fprint(w, "<html>page a</html>")
+	}))
+	defer srv.Close()
+
+	warcDir := t.TempDir()
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:     srv.URL + "/",
+		OutputDir:    t.TempDir(),
+		IgnoreRobots: true,
+		BearerToken:  "token",
+		Headers:      http.Header{"X-Api-Key": {"key-s3cret"}},
+		WARCDir:      warcDir,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if apiKey != "key-s3cret" {
+		t.Errorf("server saw X-Api-Key %q, want the configured header", apiKey)
+	}
+	if len(res.WARCFiles) != 1 {
+		t.Fatalf("WARCFiles = %v, want one file", res.WARCFiles)
+	}
+
+	f, err := os.Open(res.WARCFiles[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := string(data)
+
+	for _, want := range []string{
+		"WARC-Type: warcinfo",
+		"WARC-Type: request",
+		"WARC-Type: response",
+		"WARC-Type: metadata",
+		"WARC-Target-URI: " + srv.URL + "/a",
+		"GET /a HTTP/1.1",
+		"User-Agent: " + userAgent,
+		"HTTP/1.1 200 OK",
+		"<html>page a</html>",
+		"via: " + srv.URL + "/",
+	} {
+		if !strings.Contains(archive, want) {
+			t.Errorf("archive lacks %q", want)
+		}
+	}
+	for _, secret := range []string{"Bearer token", "session=secret", "key-s3cret", "X-Api-Key"} {
+		if strings.Contains(archive, secret) {
+			t.Errorf("archive contains %q", secret)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxRedirects matches the default limit of http.Client.
+const maxRedirects = 10
+
+// authorized reports whether credentials may be sent to u: only origins
+// listed in FetcherConfig.AuthHosts receive them. An http origin also
+// authorizes https on the same host, but never the reverse, so credentials
+// are not sent in cleartext after a scheme downgrade.
+func (f *HTTPFetcher) authorized(u *url.URL) bool {
+	host := strings.ToLower(u.Host)
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return f.authHosts["https://"+host] || f.authHosts["http://"+host]
+	case "http":
+		return f.authHosts["http://"+host]
+	}
+	return false
+}
+
+// newRequest builds a GET request carrying the User-Agent and, for
+// authorized hosts, the configured headers and credentials.
+func (f *HTTPFetcher) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if f.authorized(req.URL) {
+		f.addCredentials(req)
+	}
+	return req, nil
+}
+
+// addCredentials sets the custom headers, bearer token and basic auth on req.
+func (f *HTTPFetcher) addCredentials(req *http.Request) {
+	for name, values := range f.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if f.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+f.bearerToken)
+	}
+	if f.username != "" {
+		req.SetBasicAuth(f.username, f.password)
+	}
+}
+
+// checkRedirect strips credentials from a redirected request whose target
+// is not an authorized origin. http.Client copies the original request's
+// headers onto every redirect, and only drops Authorization and Cookie when
+// the domain changes, not when it leaves our scope.
+func (f *HTTPFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if !f.authorized(req.URL) {
+		for name := range f.headers {
+			req.Header.Del(name)
+		}
+		req.Header.Del("Authorization")
+		req.Header.Del("Cookie")
+	}
+	return nil
+}
+
+// scopedJar is a cookie jar that only reveals cookies to authorized hosts.
+type scopedJar struct {
+	jar        http.CookieJar
+	authorized func(*url.URL) bool
+}
+
+func (j scopedJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+}
+
+func (j scopedJar) Cookies(u *url.URL) []*http.Cookie {
+	if !j.authorized(u) {
+		return nil
+	}
+	return j.jar.Cookies(u)
+}
+
+// LoadCookieFile reads a Netscape cookies.txt file (as exported by browsers
+// and curl) into a cookie jar. Lines prefixed with "#HttpOnly_" are kept;
+// other comments and malformed lines are ignored.
+func LoadCookieFile(path string) (http.CookieJar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cookies: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, fmt.Errorf("cookies: %w", err)
+	}
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		c, ok := parseCookieLine(sc.Text())
+		if !ok {
+			continue
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		host := strings.TrimPrefix(c.Domain, ".")
+		if !strings.HasPrefix(c.Domain, ".") {
+			c.Domain = ""
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: "/"}, []*http.Cookie{c})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cookies: read %s: %w", path, err)
+	}
+	return jar, nil
+}
+
+// parseCookieLine parses one tab-separated cookies.txt line:
+// domain, include-subdomains, path, secure, expiry, name, value.
+// The returned cookie's Domain keeps a leading "." when it applies to
+// subdomains.
+func parseCookieLine(line string) (*http.Cookie, bool) {
+	httpOnly := false
+	if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+		line, httpOnly = rest, true
+	}
+	line = strings.TrimRight(line, "\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, false
+	}
+	fields := strings.Split(line, "\t")
+	if len(fields) != 7 {
+		return nil, false
+	}
+	domain := strings.ToLower(strings.TrimPrefix(fields[0], "."))
+	if domain == "" || fields[5] == "" {
+		return nil, false
+	}
+	if strings.EqualFold(fields[1], "TRUE") {
+		domain = "." + domain
+	}
+	c := &http.Cookie{
+		Domain:   domain,
+		Path:     fields[2],
+		Secure:   strings.EqualFold(fields[3], "TRUE"),
+		HttpOnly: httpOnly,
+		Name:     fields[5],
+		Value:    fields[6],
+	}
+	if exp, err := strconv.ParseInt(fields[4], 10, 64); err == nil && exp > 0 {
+		c.Expires = time.Unix(exp, 0)
+	}
+	return c, true
+}
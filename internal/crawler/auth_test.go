@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetchCredentialsOnlyInScope(t *testing.T) {
+	type seen struct{ auth, key, cookie string }
+	record := func(got *seen) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			*got = seen{r.Header.Get("Authorization"), r.Header.Get("X-Api-Key"), r.Header.Get("Cookie")}
+			fmt.Fprint(w, "ok")
+		}
+	}
+
+	var outside seen
+	other := httptest.NewServer(record(&outside))
+	defer other.Close()
+
+	var inside seen
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", record(&inside))
+	mux.HandleFunc("/away", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	srvURL, _ := url.Parse(srv.URL)
+	cookies := filepath.Join(t.TempDir(), "cookies.txt")
+	line := strings.Join([]string{srvURL.Hostname(), "FALSE", "/", "FALSE", "0", "session", "s3cret"}, "\t")
+	if err := os.WriteFile(cookies, []byte("# Netscape HTTP Cookie File\n"+line+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jar, err := LoadCookieFile(cookies)
+	if err != nil {
+		t.Fatalf("LoadCookieFile: %v", err)
+	}
+
+	f := mustFetcher(t, FetcherConfig{
+		Headers:     http.Header{"X-Api-Key": {"k"}},
+		BearerToken: "tok",
+		Cookies:     jar,
+		AuthHosts:   []string{srv.URL},
+	})
+	ctx := context.Background()
+
+	if _, err := f.Fetch(ctx, srv.URL+"/page"); err != nil {
+		t.Fatalf("fetch in scope: %v", err)
+	}
+	if want := (seen{"Bearer tok", "k", "session=s3cret"}); inside != want {
+		t.Errorf("in-scope request = %+v, want %+v", inside, want)
+	}
+
+	if _, err := f.Fetch(ctx, srv.URL+"/away"); err != nil {
+		t.Fatalf("fetch redirect: %v", err)
+	}
+	if outside != (seen{}) {
+		t.Errorf("redirect out of scope leaked credentials: %+v", outside)
+	}
+}
+
+func TestFetchCredentialsNotDowngraded(t *testing.T) {
+	got := make(map[string]string)
+	f := mustFetcher(t, FetcherConfig{
+		Headers:     http.Header{"X-Api-Key": {"k"}},
+		BearerToken: "tok",
+		AuthHosts:   []string{"https://secure.example", "http://plain.example"},
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got[r.URL.String()] = r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api-Key")
+			resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody, Request: r}
+			if r.URL.Path == "/away" {
+				to := *r.URL
+				to.Scheme = map[string]string{"https": "http", "http": "https"}[r.URL.Scheme]
+				to.Path = "/landing"
+				resp.StatusCode = http.StatusFound
+				resp.Header.Set("Location", to.String())
+			}
+			return resp, nil
+		}),
+	})
+
+	for _, u := range []string{
+		"https://secure.example/page",
+		"http://secure.example/page",
+		"https://secure.example/away", // → http://secure.example/landing
+		"http://plain.example/away",   // → https://plain.example/landing
+	} {
+		if _, err := f.Fetch(context.Background(), u); err != nil {
+			t.Fatalf("Fetch %s: %v", u, err)
+		}
+	}
+
+	const creds = "Bearer tok|k"
+	want := map[string]string{
+		"https://secure.example/page":   creds,
+		"http://secure.example/page":    "|",
+		"https://secure.example/away":   creds,
+		"http://secure.example/landing": "|",
+		"http://plain.example/away":     creds,
+		"https://plain.example/landing": creds,
+	}
+	for u, w := range want {
+		if got[u] != w {
+			t.Errorf("%s: credentials = %q, want %q", u, got[u], w)
+		}
+	}
+}
+
+func TestParseCookieLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		ok       bool
+		domain   string
+		httpOnly bool
+		secure   bool
+	}{
+		{"example.com\tFALSE\t/\tFALSE\t0\tsid\tabc", true, "example.com", false, false},
+		{".example.com\tTRUE\t/docs\tTRUE\t2000000000\tsid\tabc", true, ".example.com", false, true},
+		{"#HttpOnly_example.com\tFALSE\t/\tFALSE\t0\tsid\tabc", true, "example.com", true, false},
+		{"# comment", false, "", false, false},
+		{"", false, "", false, false},
+		{"example.com\tFALSE\t/", false, "", false, false},
+	}
+	for _, tc := range tests {
+		c, ok := parseCookieLine(tc.line)
+		if ok != tc.ok {
+			t.Errorf("parseCookieLine(%q) ok = %v, want %v", tc.line, ok, tc.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if c.Domain != tc.domain || c.HttpOnly != tc.httpOnly || c.Secure != tc.secure || c.Name != "sid" || c.Value != "abc" {
+			t.Errorf("parseCookieLine(%q) = %+v", tc.line, c)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package crawler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// spoolThreshold is the body size above which a response is written to a
+// temporary file instead of being held in memory.
+const spoolThreshold = 8 << 20
+
+// ErrBodyTooLarge is wrapped by fetch errors for responses larger than
+// FetcherConfig.MaxBodySize.
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// Bytes returns the response body, reading it from BodyFile when it was
+// spooled to disk.
+func (r *Response) Bytes() ([]byte, error) {
+	if r.BodyFile == "" {
+		return r.Body, nil
+	}
+	return os.ReadFile(r.BodyFile)
+}
+
+// Open returns a reader for the response body, streaming it from BodyFile
+// when it was spooled to disk.
+func (r *Response) Open() (io.ReadCloser, error) {
+	if r.BodyFile == "" {
+		return io.NopCloser(bytes.NewReader(r.Body)), nil
+	}
+	return os.Open(r.BodyFile)
+}
+
+// Close removes the temporary file of a spooled body. It is safe to call on
+// any response, and after the file has been moved elsewhere.
+func (r *Response) Close() error {
+	if r.BodyFile == "" {
+		return nil
+	}
+	err := os.Remove(r.BodyFile)
+	r.BodyFile = ""
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// readBody reads rc up to limit bytes (0 = unlimited). Bodies up to
+// spoolThreshold are returned in memory; larger ones are streamed to a
+// temporary file whose path is returned instead.
+func readBody(rc io.Reader, limit int64) (data []byte, file string, size int64, err error) {
+	src := rc
+	if limit > 0 {
+		src = io.LimitReader(rc, limit+1)
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, src, spoolThreshold+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, "", 0, err
+	}
+	if n <= spoolThreshold {
+		if limit > 0 && n > limit {
+			return nil, "", 0, fmt.Errorf("%w: exceeds %d bytes", ErrBodyTooLarge, limit)
+		}
+		return buf.Bytes(), "", n, nil
+	}
+
+	tmp, err := os.CreateTemp("", "golm-body-*")
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("spool body: %w", err)
+	}
+	size, err = io.Copy(tmp, io.MultiReader(&buf, src))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && limit > 0 && size > limit {
+		err = fmt.Errorf("%w: exceeds %d bytes", ErrBodyTooLarge, limit)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return nil, "", 0, err
+	}
+	return nil, tmp.Name(), size, nil
+}
+
+// spoolFile copies the file at src into a new temporary file, so the copy
+// can be handed out as a Response.BodyFile.
+func spoolFile(src string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp("", "golm-body-*")
+	if err != nil {
+		return "", fmt.Errorf("spool body: %w", err)
+	}
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// copyFile writes the contents of src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// moveFile renames src to dst, copying when they are on different
+// filesystems.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// hashResponse returns the hex SHA-256 of a response body.
+func hashResponse(r *Response) (string, error) {
+	if r.BodyFile == "" {
+		return contentHash(r.Body), nil
+	}
+	f, err := os.Open(r.BodyFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sniffBytes returns the first 512 bytes of a response body, as used by
+// http.DetectContentType.
+func sniffBytes(r *Response) []byte {
+	if r.BodyFile == "" {
+		return r.Body
+	}
+	f, err := os.Open(r.BodyFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+	return buf[:n]
+}
@@ -0,0 +1,77 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	small := strings.Repeat("a", 100)
+	data, file, size, err := readBody(strings.NewReader(small), 0)
+	if err != nil || file != "" || string(data) != small || size != 100 {
+		t.Errorf("small body: data %d bytes, file %q, size %d, err %v", len(data), file, size, err)
+	}
+
+	if _, _, _, err := readBody(strings.NewReader(small), 99); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("over limit: err = %v, want ErrBodyTooLarge", err)
+	}
+	if _, _, _, err := readBody(strings.NewReader(small), 100); err != nil {
+		t.Errorf("at limit: err = %v", err)
+	}
+
+	large := bytes.Repeat([]byte("b"), spoolThreshold+10)
+	data, file, size, err = readBody(bytes.NewReader(large), 0)
+	if err != nil {
+		t.Fatalf("large body: %v", err)
+	}
+	defer os.Remove(file)
+	if data != nil || file == "" || size != int64(len(large)) {
+		t.Fatalf("large body: data %d bytes, file %q, size %d", len(data), file, size)
+	}
+	got, err := os.ReadFile(file)
+	if err != nil || !bytes.Equal(got, large) {
+		t.Errorf("spooled file differs from body (err %v)", err)
+	}
+
+	if _, _, _, err := readBody(bytes.NewReader(large), spoolThreshold+1); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("large over limit: err = %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sized", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})
+	mux.HandleFunc("/chunked", func(w http.ResponseWriter, r *http.Request) {
+		// Flushing forces chunked encoding, so no Content-Length is sent.
+		for i := 0; i < 10; i++ {
+			fmt.Fprint(w, body[:100])
+			w.(http.Flusher).Flush()
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{MaxBodySize: 500})
+	for _, path := range []string{"/sized", "/chunked"} {
+		_, err := f.Fetch(context.Background(), srv.URL+path)
+		var fe *FetchError
+		if !errors.Is(err, ErrBodyTooLarge) || !errors.As(err, &fe) || fe.Transient {
+			t.Errorf("%s: err = %v, want permanent ErrBodyTooLarge", path, err)
+		}
+	}
+
+	resp, err := mustFetcher(t, FetcherConfig{MaxBodySize: 1000}).Fetch(context.Background(), srv.URL+"/sized")
+	if err != nil || resp.Size != 1000 {
+		t.Errorf("at limit: size %v, err %v", resp, err)
+	}
+}
@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheMeta is stored next to each cached body as "<key>.json".
+type cacheMeta struct {
+	URL          string      `json:"url"`
+	FinalURL     string      `json:"final_url"`
+	StatusCode   int         `json:"status_code"`
+	Proto        string      `json:"proto,omitempty"`
+	ContentType  string      `json:"content_type,omitempty"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	FetchedAt    time.Time   `json:"fetched_at"`
+	Header       http.Header `json:"header,omitempty"`
+}
+
+func cacheKey(rawURL string) string {
+	h := sha256.Sum256([]byte(rawURL))
+	return fmt.Sprintf("%x", h)
+}
+
+func (f *HTTPFetcher) cachePath(rawURL string) string {
+	return filepath.Join(f.cacheDir, cacheKey(rawURL))
+}
+
+// fresh reports whether a cached response may be served without revalidation.
+func (f *HTTPFetcher) fresh(r *Response) bool {
+	return f.cacheTTL <= 0 || time.Since(r.FetchedAt) < f.cacheTTL
+}
+
+// readCache loads the metadata of a cached response; loadCachedBody reads
+// its body once it is known to be needed. Entries written before metadata
+// was stored are returned as an error so they are refetched.
+func (f *HTTPFetcher) readCache(rawURL string) (*Response, error) {
+	path := f.cachePath(rawURL)
+	metaData, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(metaData, &meta); err != nil {
+		return nil, fmt.Errorf("cache meta %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := meta.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	finalURL := meta.FinalURL
+	if finalURL == "" {
+		finalURL = rawURL
+	}
+	return &Response{
+		URL:        rawURL,
+		FinalURL:   finalURL,
+		StatusCode: meta.StatusCode,
+		Proto:      meta.Proto,
+		Header:     header,
+		Size:       info.Size(),
+		FetchedAt:  meta.FetchedAt,
+		FromCache:  true,
+	}, nil
+}
+
+// loadCachedBody reads the cached body of r. Large bodies are handed out as
+// a private temporary copy, like a spooled download, so callers may move or
+// delete it.
+func (f *HTTPFetcher) loadCachedBody(rawURL string, r *Response) error {
+	path := f.cachePath(rawURL)
+	var err error
+	if r.Size > spoolThreshold {
+		r.BodyFile, err = spoolFile(path)
+	} else {
+		r.Body, err = os.ReadFile(path)
+	}
+	return err
+}
+
+// writeCache stores the body and metadata of r.
+func (f *HTTPFetcher) writeCache(rawURL string, r *Response) error {
+	if err := os.MkdirAll(f.cacheDir, 0o755); err != nil {
+		return err
+	}
+	if r.BodyFile != "" {
+		if err := copyFile(r.BodyFile, f.cachePath(rawURL)); err != nil {
+			return err
+		}
+	} else if err := os.WriteFile(f.cachePath(rawURL), r.Body, 0o644); err != nil {
+		return err
+	}
+	return f.writeCacheMeta(rawURL, r)
+}
+
+// writeCacheMeta stores only the metadata of r (used after a 304).
+func (f *HTTPFetcher) writeCacheMeta(rawURL string, r *Response) error {
+	meta := cacheMeta{
+		URL:          rawURL,
+		FinalURL:     r.FinalURL,
+		StatusCode:   r.StatusCode,
+		Proto:        r.Proto,
+		ContentType:  r.Header.Get("Content-Type"),
+		ETag:         r.Header.Get("ETag"),
+		LastModified: r.Header.Get("Last-Modified"),
+		FetchedAt:    r.FetchedAt,
+		Header:       withoutCookies(r.Header),
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.cachePath(rawURL)+".json", data, 0o644)
+}
+
+// withoutCookies returns a copy of h without Set-Cookie, so session cookies
+// are never written to the cache.
+func withoutCookies(h http.Header) http.Header {
+	if h.Get("Set-Cookie") == "" {
+		return h
+	}
+	h = h.Clone()
+	h.Del("Set-Cookie")
+	return h
+}
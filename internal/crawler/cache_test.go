@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchCacheRevalidation(t *testing.T) {
+	var full, notModified int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html>hello</html>")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{CacheDir: t.TempDir(), CacheTTL: time.Hour})
+	ctx := context.Background()
+
+	first, err := f.Fetch(ctx, srv.URL+"/old")
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if first.FromCache || first.FinalURL != srv.URL+"/new" {
+		t.Errorf("first = FromCache %v FinalURL %q", first.FromCache, first.FinalURL)
+	}
+
+	// Fresh entry: served without any request, with the real final URL.
+	second, err := f.Fetch(ctx, srv.URL+"/old")
+	if err != nil {
+		t.Fatalf("second fetch: %v", err)
+	}
+	if !second.FromCache || second.FinalURL != srv.URL+"/new" || string(second.Body) != "<html>hello</html>" {
+		t.Errorf("second = %+v", second)
+	}
+	if second.Header.Get("Content-Type") != "text/html" {
+		t.Errorf("cached Content-Type = %q", second.Header.Get("Content-Type"))
+	}
+
+	// Stale entry: revalidated with If-None-Match and reused on 304.
+	f.cacheTTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	third, err := f.Fetch(ctx, srv.URL+"/old")
+	if err != nil {
+		t.Fatalf("third fetch: %v", err)
+	}
+	if !third.FromCache || string(third.Body) != "<html>hello</html>" {
+		t.Errorf("third = %+v", third)
+	}
+
+	if full != 1 || notModified != 1 {
+		t.Errorf("full downloads = %d, 304s = %d; want 1 and 1", full, notModified)
+	}
+}
+
+func TestFetchCacheRevalidationLargeBody(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	version := "v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + version + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprint(w, strings.Repeat(version, spoolThreshold))
+	}))
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{CacheDir: t.TempDir(), CacheTTL: time.Nanosecond})
+	fetch := func(wantCache bool) {
+		t.Helper()
+		time.Sleep(time.Millisecond)
+		resp, err := f.Fetch(context.Background(), srv.URL+"/big")
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if resp.FromCache != wantCache || resp.BodyFile == "" {
+			t.Errorf("FromCache = %v, BodyFile = %q; want %v and a spooled body", resp.FromCache, resp.BodyFile, wantCache)
+		}
+		if err := resp.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		// Stale entries are only copied out of the cache when reused, so
+		// nothing is left behind once the response is closed.
+		if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+			t.Errorf("temp dir not clean: %d entries left", len(entries))
+		}
+	}
+
+	fetch(false) // initial download
+	fetch(true)  // 304: the cached body is reused
+	version = "v2"
+	fetch(false) // 200: the stale entry is replaced
+}
@@ -1,29 +1,302 @@
 package crawler
 
-import "time"
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
 
 // CrawlConfig holds all parameters for a crawl run.
 type CrawlConfig struct {
 	// StartURL is the seed URL (scope is derived from its host+path prefix).
+	// file:// URLs crawl a local directory such as a static site build.
 	StartURL string
+	// Seeds are additional seed URLs, each adding its own host+path prefix
+	// to the scope. All seeds share the visited set, rate limit and OutputDir.
+	Seeds []string
+	// ScopeMode selects which hosts links are followed to ("" = ScopeHost,
+	// or ScopeHosts when AllowedHosts is set).
+	ScopeMode ScopeMode
+	// AllowedHosts are extra "host[/path-prefix]" scopes for ScopeHosts,
+	// e.g. "api.example.com/v2" or "*.cdn.example.com".
+	AllowedHosts []string
+	// Fetcher retrieves pages (nil = an HTTPFetcher built from the settings
+	// below, or a FileFetcher when every seed is a file:// URL).
+	Fetcher Fetcher
 	// OutputDir is the directory where downloaded HTML files are saved.
 	OutputDir string
 	// MaxPages is the maximum number of pages to crawl (0 = unlimited).
 	MaxPages int
-	// Delay is the minimum time between requests (per-domain rate limiter).
+	// MaxDepth is the maximum number of links followed from the seed
+	// (0 = unlimited). The seed and sitemap URLs have depth 0.
+	MaxDepth int
+	// Order selects which queued URLs are crawled first ("" =
+	// OrderDiscovery, or OrderPriority when URLWeights is set). It matters
+	// most when MaxPages cuts the crawl short.
+	Order FrontierOrder
+	// URLWeights adjust the priority of matching URLs under OrderPriority,
+	// e.g. {"/guide/*", 1} or {"*/release-notes/*", -1}; the first match wins.
+	URLWeights []URLWeight
+	// Delay is the minimum time between requests to one host.
 	Delay time.Duration
-	// MaxConcurrency is the number of parallel HTTP workers.
+	// MaxConcurrency is the number of parallel HTTP workers, an overall
+	// ceiling on requests in flight across all hosts.
 	MaxConcurrency int
+	// MaxPerHost caps the requests in flight to any one host (0 = unlimited).
+	MaxPerHost int
+	// HostLimits override Delay and MaxPerHost for matching hosts.
+	HostLimits []HostLimit
+	// AdaptiveDelay slows a host down while its latency or error rate rises.
+	AdaptiveDelay bool
 	// CacheDir is an optional disk-cache directory for HTTP responses.
 	CacheDir string
+	// CacheTTL is how long cached responses are used before being
+	// revalidated with a conditional request (0 = never revalidate).
+	CacheTTL time.Duration
+	// MaxRetries is how many times a transient failure (timeout, connection
+	// reset, 429, 5xx) is retried (0 = none).
+	MaxRetries int
+	// RetryBackoff is the base delay for exponential backoff between retries
+	// (0 = 1s).
+	RetryBackoff time.Duration
 	// RetryURLs is an optional list of URLs to retry (from a previous report).
 	RetryURLs []string
+	// Resume continues an interrupted crawl from the state file in OutputDir.
+	Resume bool
+	// Incremental compares the crawl with the previous run's manifest in
+	// OutputDir: unchanged pages are not rewritten and every page is marked
+	// added, changed, unchanged or removed (PageInfo.Change).
+	Incremental bool
+	// Prune deletes the files of removed pages in an incremental crawl.
+	Prune bool
+	// IgnoreRobots disables robots.txt checks, <meta name="robots"> noindex
+	// and nofollow, and rel="nofollow" links (for sites you own).
+	IgnoreRobots bool
+	// FollowLinkRels also follows navigational <link> elements (rel next,
+	// prev and alternate with hreflang) in addition to <a href> links.
+	FollowLinkRels bool
+	// UseSitemaps seeds the queue from the site's sitemaps, discovered via
+	// robots.txt Sitemap: lines or SitemapURLs.
+	UseSitemaps bool
+	// SitemapURLs is an optional list of explicit sitemap URLs (implies UseSitemaps).
+	SitemapURLs []string
+	// SitemapOnly crawls only the seed and sitemap URLs, without following links
+	// (implies UseSitemaps).
+	SitemapOnly bool
+	// Include restricts discovered in-scope URLs to those matching at least
+	// one rule (glob, or regexp with a "re:" prefix). Empty = no restriction.
+	Include []string
+	// Exclude drops discovered in-scope URLs matching any rule (same syntax as Include).
+	Exclude []string
+	// Normalize controls query-string normalization of every crawled URL
+	// (dropping tracking parameters, sorting, or ignoring the query).
+	Normalize NormalizeOptions
+	// AllowTypes lists non-HTML media types to save (e.g. "application/pdf",
+	// "text/*"). HTML is always saved; other types are skipped.
+	AllowTypes []string
+	// FilesDir is where allowed non-HTML files are saved, with their real
+	// extension ("" = OutputDir/_files).
+	FilesDir string
+	// WARCDir is an optional directory where every fetched response is also
+	// archived as gzipped WARC 1.1 request, response and metadata records
+	// ("" = disabled).
+	WARCDir string
+	// WARCMaxSize is the size in bytes after which a new WARC file is
+	// started (0 = 1 GiB).
+	WARCMaxSize int64
+
+	// Headers are extra request headers (e.g. an API key) sent to in-scope hosts.
+	Headers http.Header
+	// BearerToken is sent as "Authorization: Bearer <token>" to in-scope hosts.
+	BearerToken string
+	// Username and Password enable HTTP basic auth for in-scope hosts.
+	Username string
+	Password string
+	// CookieFile is an optional Netscape cookies.txt file whose cookies are
+	// sent to in-scope hosts.
+	CookieFile string
+
+	// Timeout is the limit for a single request (0 = 30s).
+	Timeout time.Duration
+	// Proxy is an HTTP(S) proxy URL ("" = from HTTP_PROXY/HTTPS_PROXY).
+	Proxy string
+	// CACertFiles are extra PEM root certificates to trust (e.g. a
+	// corporate CA).
+	CACertFiles []string
+	// ClientCert and ClientKey are PEM files for mutual TLS.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables TLS certificate verification.
+	InsecureSkipVerify bool
+	// MaxBodySize is the largest response accepted, in bytes (0 = unlimited).
+	// Larger responses are recorded as errors without being saved.
+	MaxBodySize int64
+}
+
+// fetcherConfig returns the HTTPFetcher settings for this crawl.
+func (c CrawlConfig) fetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		Delay:              c.Delay,
+		MaxInFlightPerHost: c.MaxPerHost,
+		HostLimits:         c.HostLimits,
+		AdaptiveDelay:      c.AdaptiveDelay,
+		CacheDir:           c.CacheDir,
+		CacheTTL:           c.CacheTTL,
+		MaxRetries:         c.MaxRetries,
+		RetryBackoff:       c.RetryBackoff,
+		Headers:            c.Headers,
+		BearerToken:        c.BearerToken,
+		Username:           c.Username,
+		Password:           c.Password,
+		AuthHosts:          c.authHosts(),
+		Timeout:            c.Timeout,
+		Proxy:              c.Proxy,
+		CACertFiles:        c.CACertFiles,
+		ClientCert:         c.ClientCert,
+		ClientKey:          c.ClientKey,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MaxBodySize:        c.MaxBodySize,
+	}
+}
+
+// scopeMode returns the effective scope mode.
+func (c CrawlConfig) scopeMode() ScopeMode {
+	if c.ScopeMode == "" && len(c.AllowedHosts) > 0 {
+		return ScopeHosts
+	}
+	return c.ScopeMode
+}
+
+// frontierOrder returns the effective frontier order.
+func (c CrawlConfig) frontierOrder() (FrontierOrder, error) {
+	switch c.Order {
+	case "":
+		if len(c.URLWeights) > 0 {
+			return OrderPriority, nil
+		}
+		return OrderDiscovery, nil
+	case OrderDiscovery, OrderDepth, OrderPriority:
+		return c.Order, nil
+	}
+	return "", fmt.Errorf("unknown crawl order %q (want discovery, depth or priority)", c.Order)
+}
+
+// newFetcher builds the default Fetcher for this crawl: a FileFetcher when
+// every seed is a file:// URL, an HTTPFetcher otherwise.
+func (c CrawlConfig) newFetcher() (Fetcher, error) {
+	local := 0
+	seeds := c.seedURLs()
+	for _, s := range seeds {
+		if strings.HasPrefix(strings.ToLower(s), "file:") {
+			local++
+		}
+	}
+	switch {
+	case local > 0 && local == len(seeds):
+		return &FileFetcher{MaxBodySize: c.MaxBodySize}, nil
+	case local > 0:
+		return nil, fmt.Errorf("cannot mix file:// and http(s) seed URLs in one crawl")
+	}
+
+	fcfg := c.fetcherConfig()
+	if c.CookieFile != "" {
+		jar, err := LoadCookieFile(c.CookieFile)
+		if err != nil {
+			return nil, err
+		}
+		fcfg.Cookies = jar
+	}
+	f, err := NewHTTPFetcher(fcfg)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// seedURLs returns StartURL followed by Seeds, skipping empty entries.
+func (c CrawlConfig) seedURLs() []string {
+	var out []string
+	for _, s := range append([]string{c.StartURL}, c.Seeds...) {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// authHosts returns the origins that may receive credentials: the seeds'
+// scheme and host.
+func (c CrawlConfig) authHosts() []string {
+	var hosts []string
+	for _, s := range c.seedURLs() {
+		if u, err := url.Parse(s); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Scheme+"://"+u.Host)
+		}
+	}
+	return hosts
 }
 
 // CrawlResult summarises the outcome of a crawl run.
 type CrawlResult struct {
-	// Saved lists the output file paths that were written.
+	// Saved lists the output file paths of the saved pages, including
+	// unchanged files that an incremental crawl did not rewrite.
 	Saved []string
 	// Errors maps URL → error message for failed fetches.
 	Errors map[string]string
+	// Skipped maps URL → reason for URLs that were deliberately not fetched.
+	Skipped map[string]string
+	// Pages maps URL → metadata for every URL that was saved, skipped or failed.
+	Pages map[string]*PageInfo
+	// WARCFiles lists the WARC files written when WARCDir is set.
+	WARCFiles []string
+}
+
+// PageInfo records per-URL crawl metadata.
+type PageInfo struct {
+	// URL is the normalized URL that was queued.
+	URL string `json:"url"`
+	// FinalURL is the URL after any redirects.
+	FinalURL string `json:"final_url,omitempty"`
+	// RedirectTo is the normalized URL an HTTP or <meta> refresh redirect
+	// led to. Redirected pages are saved under this URL, or skipped as an
+	// alias when it is crawled separately.
+	RedirectTo string `json:"redirect_to,omitempty"`
+	// Path is the saved output file ("" if the page was not saved).
+	Path string `json:"path,omitempty"`
+	// Depth is the number of links followed from the seed to reach URL.
+	Depth int `json:"depth"`
+	// Seed is the seed URL (or AllowedHosts entry) whose scope contains URL.
+	Seed string `json:"seed,omitempty"`
+	// Referrer is the page URL was discovered on ("" for seeds).
+	Referrer string `json:"referrer,omitempty"`
+	// StatusCode is the HTTP status of the response.
+	StatusCode int `json:"status_code,omitempty"`
+	// Size is the response body size in bytes.
+	Size int64 `json:"size,omitempty"`
+	// FetchedAt is when the body was downloaded.
+	FetchedAt time.Time `json:"fetched_at,omitzero"`
+	// Title is the HTML <title> of the page.
+	Title string `json:"title,omitempty"`
+	// Charset is the detected encoding of an HTML page before it was
+	// transcoded to UTF-8.
+	Charset string `json:"charset,omitempty"`
+	// Attempts is the number of HTTP requests made (0 for cache hits).
+	Attempts int `json:"attempts,omitempty"`
+	// Change is ChangeAdded, ChangeChanged, ChangeUnchanged or ChangeRemoved
+	// in incremental crawls.
+	Change string `json:"change,omitempty"`
+	// ErrorClass is ClassTransient or ClassPermanent for failed fetches.
+	ErrorClass string `json:"error_class,omitempty"`
+	// ContentType is the response media type (from the header or sniffed).
+	ContentType string `json:"content_type,omitempty"`
+	// Hash is the hex SHA-256 of the response body.
+	Hash string `json:"hash,omitempty"`
+	// Canonical is the URL this page was collapsed onto: its rel=canonical
+	// target, or the first URL seen with identical content.
+	Canonical string `json:"canonical,omitempty"`
+	// SavedAs is the URL whose name Path was derived from, when it is not
+	// URL: the redirect target or rel=canonical URL.
+	SavedAs string `json:"saved_as,omitempty"`
 }
@@ -0,0 +1,77 @@
+package crawler
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// FilesDirName is the default subdirectory of OutputDir for non-HTML files.
+const FilesDirName = "_files"
+
+// knownExtensions maps media types to the extension used when saving them,
+// for types where mime.ExtensionsByType is ambiguous or platform-dependent.
+var knownExtensions = map[string]string{
+	"application/pdf":  ".pdf",
+	"text/plain":       ".txt",
+	"text/markdown":    ".md",
+	"text/csv":         ".csv",
+	"application/json": ".json",
+	"application/xml":  ".xml",
+	"text/xml":         ".xml",
+}
+
+// mediaTypeOf returns the lowercased media type of a response, from the
+// Content-Type header or, when that is missing or unparsable, by sniffing
+// the body.
+func mediaTypeOf(header http.Header, body []byte) string {
+	if ct := header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			return strings.ToLower(mt)
+		}
+	}
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	return mt
+}
+
+// isHTMLType reports whether mediaType is an HTML document type.
+func isHTMLType(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// typeAllowed reports whether mediaType matches one of the allowlist entries,
+// which are exact media types or "type/*" wildcards.
+func typeAllowed(allow []string, mediaType string) bool {
+	for _, a := range allow {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(a, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// extensionFor picks the file extension for a saved non-HTML resource:
+// the URL's own extension when it agrees with mediaType, otherwise a
+// well-known extension for the type, otherwise ".bin".
+func extensionFor(rawURL, mediaType string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if ext := strings.ToLower(path.Ext(u.Path)); ext != "" {
+			if mt, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil && mt == mediaType {
+				return ext
+			}
+		}
+	}
+	if ext, ok := knownExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
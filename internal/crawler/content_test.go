@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMediaTypeOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"header with params", "text/HTML; charset=Shift_JIS", "", "text/html"},
+		{"pdf header", "application/pdf", "%PDF-1.7", "application/pdf"},
+		{"sniffed html", "", "<!DOCTYPE html><html><body>x</body></html>", "text/html"},
+		{"sniffed pdf", "", "%PDF-1.4\n...", "application/pdf"},
+		{"invalid header falls back to sniffing", ";;", "plain words", "text/plain"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := make(http.Header)
+			if tc.contentType != "" {
+				h.Set("Content-Type", tc.contentType)
+			}
+			if got := mediaTypeOf(h, []byte(tc.body)); got != tc.want {
+				t.Errorf("mediaTypeOf = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTypeAllowed(t *testing.T) {
+	allow := []string{"application/pdf", "Text/*"}
+	tests := []struct {
+		mediaType string
+		want      bool
+	}{
+		{"application/pdf", true},
+		{"text/plain", true},
+		{"text/csv", true},
+		{"application/json", false},
+		{"image/png", false},
+	}
+	for _, tc := range tests {
+		if got := typeAllowed(allow, tc.mediaType); got != tc.want {
+			t.Errorf("typeAllowed(%q) = %v, want %v", tc.mediaType, got, tc.want)
+		}
+	}
+}
+
+func TestExtensionFor(t *testing.T) {
+	tests := []struct {
+		url       string
+		mediaType string
+		want      string
+	}{
+		{"https://example.com/docs/manual.pdf", "application/pdf", ".pdf"},
+		{"https://example.com/docs/download?id=3", "application/pdf", ".pdf"},
+		{"https://example.com/docs/LICENSE", "text/plain", ".txt"},
+		{"https://example.com/docs/page.php", "text/plain", ".txt"},
+		{"https://example.com/blob", "application/x-unknown-thing", ".bin"},
+	}
+	for _, tc := range tests {
+		if got := extensionFor(tc.url, tc.mediaType); got != tc.want {
+			t.Errorf("extensionFor(%q, %q) = %q, want %q", tc.url, tc.mediaType, got, tc.want)
+		}
+	}
+}
@@ -3,45 +3,176 @@ package crawler
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"golm-connector/internal/charset"
+	"golm-connector/internal/manifest"
+	"golm-connector/internal/warc"
 
 	"golang.org/x/net/html"
 )
 
-// Run executes a BFS crawl according to cfg.
+// Run crawls according to cfg, breadth first unless cfg.Order says otherwise.
 // It returns a CrawlResult summarising saved files and errors.
 func Run(ctx context.Context, cfg CrawlConfig) (*CrawlResult, error) {
 	if err := os.MkdirAll(cfg.OutputDir, 0o755); err != nil {
 		return nil, fmt.Errorf("mkdir output: %w", err)
 	}
 
-	fetcher := NewFetcher(cfg.Delay, cfg.CacheDir)
-	result := &CrawlResult{Errors: make(map[string]string)}
+	fetcher := cfg.Fetcher
+	if fetcher == nil {
+		f, err := cfg.newFetcher()
+		if err != nil {
+			return nil, err
+		}
+		fetcher = f
+	}
+	var archive *warc.Writer
+	result := &CrawlResult{
+		Errors:  make(map[string]string),
+		Skipped: make(map[string]string),
+		Pages:   make(map[string]*PageInfo),
+	}
+
+	if cfg.WARCDir != "" {
+		w, err := warc.NewWriter(warc.Config{Dir: cfg.WARCDir, MaxSize: cfg.WARCMaxSize, Software: userAgent})
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			result.WARCFiles = w.Files()
+			if err := w.Close(); err != nil {
+				slog.Warn("crawl: close WARC file failed", "err", err)
+			}
+		}()
+		archive = w
+	}
 
-	seed := Normalize(cfg.StartURL)
-	if seed == "" {
-		return nil, fmt.Errorf("invalid start URL: %s", cfg.StartURL)
+	var robots *robotsCache
+	if !cfg.IgnoreRobots {
+		robots = newRobotsCache(fetcher)
+	}
+
+	normalize := func(u string) string { return NormalizeWith(u, cfg.Normalize) }
+
+	var seeds []string
+	seenSeed := make(map[string]bool)
+	for _, s := range cfg.seedURLs() {
+		n := normalize(s)
+		if n == "" {
+			return nil, fmt.Errorf("invalid start URL: %s", s)
+		}
+		if !seenSeed[n] {
+			seenSeed[n] = true
+			seeds = append(seeds, n)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no start URL")
+	}
+	sc, err := newScope(seeds, cfg.scopeMode(), cfg.AllowedHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := compileScopeRules(cfg.Include, cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	order, err := cfg.frontierOrder()
+	if err != nil {
+		return nil, err
+	}
+	weights, err := compileURLWeights(cfg.URLWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	filesDir := cfg.FilesDir
+	if filesDir == "" {
+		filesDir = filepath.Join(cfg.OutputDir, FilesDirName)
+	}
+
+	var state *crawlState
+	if cfg.Resume {
+		if state, err = loadState(cfg.OutputDir); err != nil {
+			return nil, err
+		}
+		if !slices.Equal(state.Seeds, seeds) {
+			return nil, fmt.Errorf("resume: state in %s is for seeds %v, not %v", cfg.OutputDir, state.Seeds, seeds)
+		}
+	}
+
+	// An incremental crawl compares against the manifest of the previous
+	// run (kept in the state file while a resumable crawl is in progress).
+	var prev *previousRun
+	if cfg.Incremental {
+		var entries []manifest.Entry
+		if state != nil {
+			entries = state.Previous
+		} else if entries, err = loadPreviousEntries(cfg.OutputDir); err != nil {
+			return nil, err
+		}
+		prev = newPreviousRun(cfg.OutputDir, entries)
+		slog.Info("crawl: incremental", "previous_pages", len(entries))
 	}
 
 	// Seed the initial queue.
-	initialURLs := []string{seed}
+	initialURLs := seeds
+	var sitemapURLs []string
+	sitemapPriority := make(map[string]float64)
 	if len(cfg.RetryURLs) > 0 {
 		initialURLs = cfg.RetryURLs
+	} else if state == nil && (cfg.UseSitemaps || cfg.SitemapOnly || len(cfg.SitemapURLs) > 0) {
+		// Sitemap: lines come from the same robots.txt fetch as the rules.
+		sitemapRobots := robots
+		if sitemapRobots == nil {
+			sitemapRobots = newRobotsCache(fetcher)
+			sitemapRobots.ignoreDelay = true
+		}
+		for _, e := range discoverSitemaps(ctx, fetcher, sitemapRobots, cfg, sc) {
+			n := normalize(e.Loc)
+			sitemapURLs = append(sitemapURLs, n)
+			sitemapPriority[n] = e.Priority
+		}
 	}
+	followLinks := len(cfg.RetryURLs) == 0 && !cfg.SitemapOnly
+	linkOpts := LinkOptions{FollowLinkRels: cfg.FollowLinkRels, IgnoreNofollow: cfg.IgnoreRobots}
 
-	type fetchJob struct {
-		url string
-	}
 	type fetchRes struct {
 		url      string
+		depth    int
 		finalURL string
+		referrer string
+		status   int
+		header   http.Header
 		data     []byte
-		err      error
+		// file holds a non-HTML body spooled to disk instead of data; the
+		// dispatcher moves it into place or removes it.
+		file      string
+		size      int64
+		hash      string
+		mediaType string
+		charset   string
+		fetchedAt time.Time
+		attempts  int
+		err       error
+		// skip is the reason the URL was not fetched ("" = fetched).
+		skip string
+		// finalDisallowed is set when robots.txt disallows the redirect target.
+		finalDisallowed bool
 	}
 
 	concurrency := cfg.MaxConcurrency
@@ -49,7 +180,7 @@ func Run(ctx context.Context, cfg CrawlConfig) (*CrawlResult, error) {
 		concurrency = 5
 	}
 
-	jobs := make(chan fetchJob, concurrency*2)
+	jobs := make(chan queueItem, concurrency*2)
 	results := make(chan fetchRes, concurrency*2)
 
 	// Start worker pool.
@@ -59,8 +190,51 @@ func Run(ctx context.Context, cfg CrawlConfig) (*CrawlResult, error) {
 		go func() {
 			defer wg.Done()
 			for job := range jobs {
-				data, finalURL, err := fetcher.Do(ctx, job.url)
-				results <- fetchRes{url: job.url, finalURL: finalURL, data: data, err: err}
+				if robots != nil && !robots.Allowed(ctx, job.URL) {
+					results <- fetchRes{url: job.URL, depth: job.Depth, referrer: job.Referrer, skip: "disallowed by robots.txt"}
+					continue
+				}
+				res := fetchRes{url: job.URL, depth: job.Depth, referrer: job.Referrer}
+				if resp, err := fetcher.Fetch(ctx, job.URL); err != nil {
+					res.err = err
+					var fe *FetchError
+					if errors.As(err, &fe) {
+						res.attempts = fe.Attempts
+					}
+				} else {
+					if archive != nil {
+						if err := archiveResponse(archive, resp, job); err != nil {
+							slog.Warn("crawl: archive failed", "url", job.URL, "err", err)
+						}
+					}
+					res.finalURL = resp.FinalURL
+					if robots != nil && resp.FinalURL != job.URL {
+						if u := normalize(resp.FinalURL); u != "" && u != job.URL && sc.contains(u) {
+							res.finalDisallowed = !robots.Allowed(ctx, u)
+						}
+					}
+					res.status = resp.StatusCode
+					res.header = resp.Header
+					res.size = resp.Size
+					res.fetchedAt = resp.FetchedAt
+					res.attempts = resp.Attempts
+					res.mediaType = mediaTypeOf(resp.Header, sniffBytes(resp))
+					res.hash, res.err = hashResponse(resp)
+					// HTML is parsed in memory; other large bodies stay on disk.
+					if res.err == nil && isHTMLType(res.mediaType) {
+						res.data, res.err = resp.Bytes()
+						if res.err == nil {
+							res.data, res.charset = toUTF8(job.URL, res.data, resp.Header)
+						}
+					} else if res.err == nil {
+						res.data, res.file = resp.Body, resp.BodyFile
+						resp.BodyFile = ""
+					}
+					if err := resp.Close(); err != nil {
+						slog.Warn("crawl: remove temp file failed", "url", job.URL, "err", err)
+					}
+				}
+				results <- res
 			}
 		}()
 	}
@@ -71,96 +245,514 @@ func Run(ctx context.Context, cfg CrawlConfig) (*CrawlResult, error) {
 		close(results)
 	}()
 
-	// BFS dispatcher (runs in main goroutine).
+	// priority scores a URL for OrderPriority.
+	priority := func(link string) float64 {
+		p, ok := sitemapPriority[link]
+		if !ok {
+			p = defaultSitemapPriority
+		}
+		return p + weights.score(link)
+	}
+
+	// Dispatcher (runs in main goroutine).
 	visited := make(map[string]bool)
-	queue := make([]string, 0, len(initialURLs))
-	for _, u := range initialURLs {
-		n := Normalize(u)
-		if n != "" && !visited[n] {
-			visited[n] = true
-			queue = append(queue, n)
+	queue := newFrontier(order)
+	if state != nil {
+		var items []queueItem
+		items, visited = state.restore(result)
+		for _, it := range items {
+			queue.push(it)
+		}
+		slog.Info("crawl: resuming", "queued", queue.len(), "visited", len(visited), "saved", len(result.Saved))
+	} else {
+		for _, u := range initialURLs {
+			n := normalize(u)
+			if n != "" && !visited[n] {
+				visited[n] = true
+				queue.push(queueItem{URL: n, Priority: priority(n)})
+			}
 		}
 	}
 
+	// enqueue queues a discovered in-scope URL unless it was already seen or
+	// is rejected by the include/exclude rules.
+	enqueue := func(link string, depth int, referrer string) {
+		if visited[link] {
+			return
+		}
+		visited[link] = true
+		if reason := rules.Check(link); reason != "" {
+			slog.Debug("crawl: rejected by rule", "url", link, "reason", reason)
+			result.Skipped[link] = reason
+			result.Pages[link] = &PageInfo{URL: link, Depth: depth, Seed: sc.seedFor(link), Referrer: referrer}
+			return
+		}
+		queue.push(queueItem{URL: link, Depth: depth, Referrer: referrer, Priority: priority(link)})
+	}
+
+	// Sitemap entries are treated as additional seeds (depth 0).
+	for _, u := range sitemapURLs {
+		enqueue(u, 0, "")
+	}
+
+	// saved holds the URLs pages were saved under, and seenHash maps the
+	// content hash of each saved body to that URL, so exact duplicates are
+	// stored only once.
+	saved, seenHash := indexSaved(result.Pages)
+
+	// markSaved records that page was saved to outPath under saveURL's name.
+	markSaved := func(page *PageInfo, saveURL, outPath string) {
+		page.Path = outPath
+		if saveURL != page.URL {
+			page.SavedAs = saveURL
+		}
+		saved[saveURL] = true
+		seenHash[page.Hash] = saveURL
+	}
+
+	// store writes a fetched body to outPath, unless an incremental crawl
+	// finds the same content already there, and records the page's change.
+	store := func(res fetchRes, page *PageInfo, outPath string) error {
+		if prev != nil {
+			page.Change = prev.change(res.url, res.hash, outPath)
+			if page.Change == ChangeUnchanged {
+				return nil
+			}
+		}
+		if res.file != "" {
+			return moveSaved(outPath, res.file)
+		}
+		return writeSaved(outPath, res.data)
+	}
+
+	// inflight holds dispatched jobs so they can be persisted as part of the
+	// frontier while their results are outstanding.
+	inflight := make(map[string]queueItem)
 	pending := 0
 
 	dispatch := func() {
-		for len(queue) > 0 && pending < concurrency*2 {
+		for queue.len() > 0 && pending < concurrency*2 && ctx.Err() == nil {
 			if cfg.MaxPages > 0 && len(result.Saved)+len(result.Errors)+pending >= cfg.MaxPages {
 				break
 			}
-			url := queue[0]
-			queue = queue[1:]
-			jobs <- fetchJob{url: url}
+			job := queue.pop()
+			inflight[job.URL] = job
+			jobs <- job
 			pending++
 		}
 	}
 
-	slog.Info("crawl: started", "url", seed, "max_pages", cfg.MaxPages)
+	persist := func() {
+		frontier := make([]queueItem, 0, len(inflight)+queue.len())
+		for _, job := range inflight {
+			frontier = append(frontier, job)
+		}
+		frontier = append(frontier, queue.snapshot()...)
+		st := newCrawlState(seeds, frontier, visited, result)
+		if prev != nil {
+			st.Previous = prev.entries
+		}
+		if err := saveState(cfg.OutputDir, st); err != nil {
+			slog.Warn("crawl: save state failed", "err", err)
+		}
+	}
+	lastPersist := time.Now()
+
+	slog.Info("crawl: started", "seeds", seeds, "max_pages", cfg.MaxPages, "order", order)
 
 	dispatch()
 
 	for pending > 0 {
 		res := <-results
 		pending--
+		job := inflight[res.url]
+		delete(inflight, res.url)
+
+		// After cancellation, failures are most likely caused by the
+		// interruption itself: put the URL back for a resumed crawl.
+		if ctx.Err() != nil && (res.err != nil || res.skip != "") {
+			queue.push(job)
+			continue
+		}
+		cleanup := func() {
+			if res.file != "" {
+				if err := os.Remove(res.file); err != nil && !os.IsNotExist(err) {
+					slog.Warn("crawl: remove temp file failed", "url", res.url, "err", err)
+				}
+			}
+		}
+
+		page := &PageInfo{
+			URL:        res.url,
+			FinalURL:   res.finalURL,
+			Depth:      res.depth,
+			Seed:       sc.seedFor(res.url),
+			Referrer:   res.referrer,
+			StatusCode: res.status,
+			Size:       res.size,
+			FetchedAt:  res.fetchedAt,
+			Attempts:   res.attempts,
+		}
+		result.Pages[res.url] = page
 
-		if res.err != nil {
+		if res.skip != "" {
+			slog.Info("crawl: skipped", "url", res.url, "reason", res.skip)
+			result.Skipped[res.url] = res.skip
+		} else if res.err != nil {
 			slog.Warn("fetch error", "url", res.url, "err", res.err)
 			result.Errors[res.url] = res.err.Error()
+			page.ErrorClass = ClassPermanent
+			var fe *FetchError
+			if errors.As(res.err, &fe) {
+				page.ErrorClass = fe.Class()
+			}
+		} else if finalURL := normalize(res.finalURL); finalURL != "" && finalURL != res.url && !sc.contains(finalURL) {
+			// A redirect left the crawl scope: report it instead of saving
+			// another site's page under an in-scope name.
+			page.RedirectTo = finalURL
+			reason := "redirected out of scope to " + res.finalURL
+			slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+			result.Skipped[res.url] = reason
+		} else if reason := redirectRejection(res.finalDisallowed, rules, finalURL, res.url); reason != "" {
+			// The redirect target is excluded: the rules and robots.txt apply
+			// to it as if it had been linked directly.
+			page.RedirectTo = finalURL
+			reason = "redirected to " + finalURL + ", " + reason
+			slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+			result.Skipped[res.url] = reason
+		} else if finalURL != "" && finalURL != res.url && saved[finalURL] {
+			// The redirect target was already saved under its own name.
+			page.RedirectTo = finalURL
+			reason := "redirect alias of " + finalURL
+			slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+			result.Skipped[res.url] = reason
+		} else if hash := res.hash; seenHash[hash] != "" {
+			// Exact duplicate of a page that was already saved.
+			page.Hash = hash
+			page.Canonical = seenHash[hash]
+			reason := "duplicate of " + seenHash[hash]
+			slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+			result.Skipped[res.url] = reason
+		} else if mediaType := res.mediaType; !isHTMLType(mediaType) {
+			page.ContentType = mediaType
+			page.Hash = hash
+			if typeAllowed(cfg.AllowTypes, mediaType) {
+				saveURL := res.url
+				if finalURL != "" && finalURL != res.url {
+					page.RedirectTo = finalURL
+					if !visited[finalURL] {
+						visited[finalURL] = true
+						saveURL = finalURL
+					}
+				}
+				outPath, err := savePath(filesDir, saveURL, extensionFor(saveURL, mediaType))
+				if err == nil {
+					err = store(res, page, outPath)
+				}
+				if err != nil {
+					slog.Warn("save error", "url", res.url, "err", err)
+					result.Errors[res.url] = err.Error()
+				} else {
+					markSaved(page, saveURL, outPath)
+					result.Saved = append(result.Saved, outPath)
+					slog.Info("crawl: saved file", "n", len(result.Saved), "url", res.url, "type", mediaType, "change", page.Change)
+				}
+			} else {
+				reason := fmt.Sprintf("content type %s not saved", mediaType)
+				slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+				result.Skipped[res.url] = reason
+			}
 		} else {
-			// Parse HTML and save.
-			outPath, err := saveHTML(cfg.OutputDir, res.url, res.data)
+			page.ContentType = mediaType
+			page.Hash = hash
+			page.Charset = res.charset
+
+			base := res.finalURL
+			if base == "" {
+				base = res.url
+			}
+			doc, err := html.Parse(bytes.NewReader(res.data))
 			if err != nil {
-				slog.Warn("save error", "url", res.url, "err", err)
-				result.Errors[res.url] = err.Error()
-			} else {
-				result.Saved = append(result.Saved, outPath)
-				slog.Info("crawl: saved", "n", len(result.Saved), "url", res.url)
-				slog.Debug("crawl: saved path", "url", res.url, "path", outPath)
+				doc = nil
 			}
 
-			// Extract links and enqueue new ones (skip if retry mode).
-			if len(cfg.RetryURLs) == 0 {
-				doc, err := html.Parse(bytes.NewReader(res.data))
-				if err == nil {
-					base := res.finalURL
-					if base == "" {
-						base = res.url
+			// Save redirected pages under their final URL, unless it is
+			// queued or was already fetched under its own name.
+			saveURL, aliasOf := res.url, ""
+			if finalURL != "" && finalURL != res.url {
+				page.RedirectTo = finalURL
+				if !visited[finalURL] {
+					visited[finalURL] = true
+					saveURL = finalURL
+				}
+			}
+
+			// A <meta http-equiv="refresh"> page is a redirect stub: follow
+			// its target instead of saving it.
+			refresh := ""
+			if doc != nil {
+				page.Title = HTMLTitle(doc)
+				if r := normalize(MetaRefreshURL(base, doc)); r != "" && r != saveURL {
+					refresh = r
+				}
+			}
+
+			// Collapse aliases onto the page's rel=canonical URL: skip when the
+			// canonical page was saved, or save under the canonical name while
+			// it is unknown. A canonical URL that is queued, failed or was
+			// rejected keeps its own outcome, and this page its own name.
+			if doc != nil && refresh == "" {
+				if c := normalize(CanonicalURL(base, doc)); c != "" && c != saveURL && sc.contains(c) && crawlableURL(c) {
+					page.Canonical = c
+					switch {
+					case saved[c]:
+						aliasOf = c
+					case !visited[c]:
+						visited[c] = true
+						saveURL = c
 					}
-					for _, link := range ExtractLinks(base, doc) {
-						if !visited[link] && InScope(seed, link) && isHTTPURL(link) {
-							visited[link] = true
-							queue = append(queue, link)
+				}
+			}
+
+			if refresh != "" {
+				page.RedirectTo = refresh
+				reason := "meta refresh to " + refresh
+				if !sc.contains(refresh) || !crawlableURL(refresh) {
+					reason = "meta refresh out of scope to " + refresh
+				} else {
+					enqueue(refresh, res.depth, res.url)
+				}
+				slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+				result.Skipped[res.url] = reason
+			} else if aliasOf != "" {
+				reason := "alias of canonical " + aliasOf
+				slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+				result.Skipped[res.url] = reason
+			} else {
+				var robotsMeta RobotsDirectives
+				if doc != nil && !cfg.IgnoreRobots {
+					robotsMeta = MetaRobots(doc)
+				}
+
+				if robotsMeta.NoIndex {
+					// noindex pages are still crawled for their links.
+					reason := "noindex"
+					slog.Info("crawl: skipped", "url", res.url, "reason", reason)
+					result.Skipped[res.url] = reason
+				} else {
+					// Save the HTML.
+					outPath, err := savePath(cfg.OutputDir, saveURL, ".html")
+					if err == nil {
+						err = store(res, page, outPath)
+					}
+					if err != nil {
+						slog.Warn("save error", "url", res.url, "err", err)
+						result.Errors[res.url] = err.Error()
+					} else {
+						markSaved(page, saveURL, outPath)
+						result.Saved = append(result.Saved, outPath)
+						slog.Info("crawl: saved", "n", len(result.Saved), "url", res.url, "change", page.Change)
+						slog.Debug("crawl: saved path", "url", res.url, "path", outPath)
+					}
+				}
+
+				// Extract links and enqueue new ones (skip in retry and sitemap-only
+				// modes, on nofollow pages, and once the depth limit is reached).
+				if doc != nil && followLinks && !robotsMeta.NoFollow && (cfg.MaxDepth <= 0 || res.depth < cfg.MaxDepth) {
+					for _, link := range ExtractLinksWith(base, doc, linkOpts) {
+						link = normalize(link)
+						if sc.contains(link) && crawlableURL(link) {
+							enqueue(link, res.depth+1, res.url)
 						}
 					}
 				}
 			}
 		}
 
+		cleanup()
+
+		if time.Since(lastPersist) >= stateSaveInterval {
+			persist()
+			lastPersist = time.Now()
+		}
+
 		dispatch()
 	}
 
 	close(jobs)
 
+	// Removed pages can only be told apart from unvisited ones once the
+	// whole frontier has been crawled. A retry run only visits the retried
+	// URLs, so it never detects removals.
+	if prev != nil && ctx.Err() == nil {
+		switch {
+		case len(cfg.RetryURLs) > 0:
+			slog.Debug("crawl: retry run, not detecting removed pages")
+		case queue.len() > 0:
+			slog.Warn("crawl: page limit reached, not detecting removed pages", "queued", queue.len())
+		default:
+			prev.markRemoved(result, cfg.Prune)
+		}
+	}
+
+	if err := writeManifest(cfg.OutputDir, result, len(cfg.RetryURLs) > 0); err != nil {
+		slog.Warn("crawl: write manifest failed", "err", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		persist()
+		slog.Info("crawl: interrupted, state saved", "path", statePath(cfg.OutputDir), "queued", queue.len())
+		return result, fmt.Errorf("crawl interrupted: %w", err)
+	}
+	if err := removeState(cfg.OutputDir); err != nil {
+		slog.Warn("crawl: remove state failed", "err", err)
+	}
+
 	return result, nil
 }
 
-// saveHTML writes data to OutputDir using a path derived from the URL.
-func saveHTML(outputDir, rawURL string, data []byte) (string, error) {
-	rel := URLToFilename(rawURL)
+// toUTF8 transcodes an HTML body to UTF-8 and returns it with the detected
+// charset. Bodies that cannot be decoded are returned unchanged.
+func toUTF8(rawURL string, data []byte, header http.Header) ([]byte, string) {
+	out, name, err := charset.ToUTF8(data, header.Get("Content-Type"))
+	if err != nil {
+		slog.Warn("crawl: transcode failed", "url", rawURL, "charset", name, "err", err)
+		return data, name
+	}
+	if name != charset.UTF8 {
+		slog.Debug("crawl: transcoded", "url", rawURL, "charset", name)
+	}
+	return out, name
+}
+
+// savePath returns the path under dir for rawURL with extension ext.
+func savePath(dir, rawURL, ext string) (string, error) {
+	rel := URLToFilenameExt(rawURL, ext)
 	if rel == "" {
 		return "", fmt.Errorf("cannot derive filename from URL: %s", rawURL)
 	}
-	outPath := filepath.Join(outputDir, filepath.FromSlash(rel))
+	return filepath.Join(dir, filepath.FromSlash(rel)), nil
+}
+
+// writeSaved writes data to outPath, creating its directory.
+func writeSaved(outPath string, data []byte) error {
 	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
-		return "", err
+		return err
+	}
+	return os.WriteFile(outPath, data, 0o644)
+}
+
+// moveSaved moves a spooled body file to outPath.
+func moveSaved(outPath, file string) error {
+	if err := moveFile(file, outPath); err != nil {
+		return err
+	}
+	// Temporary files are created private; saved files are not.
+	return os.Chmod(outPath, 0o644)
+}
+
+// indexSaved returns the URLs the saved pages were saved under and a map
+// from their content hashes to those URLs.
+func indexSaved(pages map[string]*PageInfo) (map[string]bool, map[string]string) {
+	saved := make(map[string]bool)
+	seenHash := make(map[string]string)
+	for _, p := range pages {
+		if p.Path == "" {
+			continue
+		}
+		saveURL := p.URL
+		if p.SavedAs != "" {
+			saveURL = p.SavedAs
+		}
+		saved[saveURL] = true
+		if p.Hash != "" {
+			seenHash[p.Hash] = saveURL
+		}
 	}
-	if err := os.WriteFile(outPath, data, 0o644); err != nil {
-		return "", err
+	return saved, seenHash
+}
+
+// writeManifest writes the manifest of saved pages, sorted by URL, into
+// outputDir. Paths are made relative to outputDir. A retry run only saves
+// the retried pages, so with merge their entries replace or extend the
+// existing manifest instead of overwriting it.
+func writeManifest(outputDir string, res *CrawlResult, merge bool) error {
+	var entries []manifest.Entry
+	for _, p := range res.Pages {
+		if p.Path == "" {
+			continue
+		}
+		rel, err := filepath.Rel(outputDir, p.Path)
+		if err != nil {
+			rel = p.Path
+		}
+		entries = append(entries, manifest.Entry{
+			URL:         p.URL,
+			FinalURL:    p.FinalURL,
+			Path:        filepath.ToSlash(rel),
+			Status:      p.StatusCode,
+			ContentType: p.ContentType,
+			Size:        p.Size,
+			Hash:        p.Hash,
+			Depth:       p.Depth,
+			Seed:        p.Seed,
+			Referrer:    p.Referrer,
+			FetchedAt:   p.FetchedAt,
+			Title:       p.Title,
+			Charset:     p.Charset,
+		})
+	}
+	if merge {
+		old, err := loadPreviousEntries(outputDir)
+		if err != nil {
+			return err
+		}
+		entries = mergeEntries(old, entries)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return manifest.Write(filepath.Join(outputDir, manifest.FileName), entries)
+}
+
+// mergeEntries adds to entries the old entries whose URL and file were not
+// saved again.
+func mergeEntries(old, entries []manifest.Entry) []manifest.Entry {
+	urls := make(map[string]bool, len(entries))
+	paths := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		urls[e.URL] = true
+		paths[e.Path] = true
 	}
-	return outPath, nil
+	for _, e := range old {
+		if !urls[e.URL] && !paths[e.Path] {
+			entries = append(entries, e)
+		}
+	}
+	return entries
 }
 
-func isHTTPURL(u string) bool {
-	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+// contentHash returns the hex SHA-256 of a response body.
+func contentHash(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// crawlableURL reports whether u uses a scheme the crawler can fetch.
+func crawlableURL(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "file://")
+}
+
+// redirectRejection returns why the redirect from requested to finalURL
+// must not be saved, or "" if it may. disallowed reports whether robots.txt
+// forbids finalURL.
+func redirectRejection(disallowed bool, rules *scopeRules, finalURL, requested string) string {
+	if finalURL == "" || finalURL == requested {
+		return ""
+	}
+	if reason := rules.Check(finalURL); reason != "" {
+		return reason
+	}
+	if disallowed {
+		return "disallowed by robots.txt"
+	}
+	return ""
 }
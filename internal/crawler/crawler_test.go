@@ -0,0 +1,379 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"golm-connector/internal/manifest"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// siteServer serves pages by path, answering 404 for anything else, and
+// records the paths that were requested. A page of the form "-> /target"
+// is a 301 redirect to /target.
+type siteServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	requested map[string]int
+}
+
+func newSiteServer(t *testing.T, pages map[string]string) *siteServer {
+	t.Helper()
+	s := &siteServer{requested: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requested[r.URL.Path]++
+		s.mu.Unlock()
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if target, ok := strings.CutPrefix(body, "-> "); ok {
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *siteServer) fetched(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requested[path] > 0
+}
+
+func TestRunMaxDepth(t *testing.T) {
+	srv := newSiteServer(t, map[string]string{
+		"/docs":       `<html><a href="/docs/a">a</a></html>`,
+		"/docs/a":     `<html><a href="/docs/a/b">b</a></html>`,
+		"/docs/a/b":   `<html><a href="/docs/a/b/c">c</a></html>`,
+		"/docs/a/b/c": `<html>c</html>`,
+	})
+
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:     srv.URL + "/docs",
+		OutputDir:    t.TempDir(),
+		IgnoreRobots: true,
+		MaxDepth:     2,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := map[string]struct {
+		depth    int
+		referrer string
+	}{
+		"/docs":     {0, ""},
+		"/docs/a":   {1, srv.URL + "/docs"},
+		"/docs/a/b": {2, srv.URL + "/docs/a"},
+	}
+	for path, w := range want {
+		p := res.Pages[srv.URL+path]
+		if p == nil || p.Path == "" || p.Depth != w.depth || p.Referrer != w.referrer {
+			t.Errorf("%s: page = %+v, want saved at depth %d via %q", path, p, w.depth, w.referrer)
+		}
+	}
+	if srv.fetched("/docs/a/b/c") || res.Pages[srv.URL+"/docs/a/b/c"] != nil {
+		t.Error("link beyond MaxDepth was fetched")
+	}
+}
+
+func TestRunDuplicatesAndCanonical(t *testing.T) {
+	canonical := func(path, body string) string {
+		return `<html><head><link rel="canonical" href="` + path + `"></head>` + body + `</html>`
+	}
+	srv := newSiteServer(t, map[string]string{
+		"/docs": `<html><a href="/docs/gone">gone</a><a href="/docs/private">private</a>` +
+			`<a href="/docs/copy1">1</a><a href="/docs/copy2">2</a>` +
+			`<a href="/docs/a">a</a><a href="/docs/b">b</a><a href="/docs/c">c</a><a href="/docs/d">d</a></html>`,
+		"/docs/copy1": `<html>same</html>`,
+		"/docs/copy2": `<html>same</html>`,
+		"/docs/a":     canonical("/docs/gone", "a"),
+		"/docs/b":     canonical("/docs/private", "b"),
+		"/docs/c":     canonical("/docs", "c"),
+		"/docs/d":     canonical("/docs/new", "d"),
+	})
+
+	out := t.TempDir()
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:       srv.URL + "/docs",
+		OutputDir:      out,
+		IgnoreRobots:   true,
+		MaxConcurrency: 1,
+		Exclude:        []string{"/docs/private"},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := res.Skipped[srv.URL+"/docs/copy2"]; got != "duplicate of "+srv.URL+"/docs/copy1" {
+		t.Errorf("/docs/copy2 skip reason = %q, want duplicate of /docs/copy1", got)
+	}
+	// Canonical targets that failed or were rejected were never saved, so
+	// the pages naming them keep their content under their own name.
+	for _, path := range []string{"/docs/a", "/docs/b"} {
+		p := res.Pages[srv.URL+path]
+		if p == nil || p.Path != filepath.Join(out, URLToFilename(srv.URL+path)) || p.SavedAs != "" {
+			t.Errorf("%s: page = %+v, want saved under its own URL", path, p)
+		}
+	}
+	if got := res.Skipped[srv.URL+"/docs/c"]; got != "alias of canonical "+srv.URL+"/docs" {
+		t.Errorf("/docs/c skip reason = %q, want alias of the saved seed", got)
+	}
+	if p := res.Pages[srv.URL+"/docs/d"]; p == nil || p.SavedAs != srv.URL+"/docs/new" ||
+		p.Path != filepath.Join(out, URLToFilename(srv.URL+"/docs/new")) {
+		t.Errorf("/docs/d: page = %+v, want saved under its unseen canonical URL", p)
+	}
+}
+
+func TestIndexSaved(t *testing.T) {
+	saved, seenHash := indexSaved(map[string]*PageInfo{
+		"https://example.com/a":     {URL: "https://example.com/a", Path: "a.html", Hash: "h1"},
+		"https://example.com/b?x=1": {URL: "https://example.com/b?x=1", SavedAs: "https://example.com/b", Path: "b.html", Hash: "h2"},
+		"https://example.com/c":     {URL: "https://example.com/c", Hash: "h3"},
+	})
+	if !saved["https://example.com/a"] || !saved["https://example.com/b"] || saved["https://example.com/b?x=1"] || saved["https://example.com/c"] {
+		t.Errorf("saved = %v", saved)
+	}
+	if seenHash["h2"] != "https://example.com/b" || seenHash["h3"] != "" {
+		t.Errorf("seenHash = %v", seenHash)
+	}
+}
+
+func TestRunRedirects(t *testing.T) {
+	srv := newSiteServer(t, map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /docs/secret\n",
+		"/docs": `<html><a href="/docs/old">old</a><a href="/docs/again">again</a>` +
+			`<a href="/docs/moved">moved</a><a href="/docs/blocked">blocked</a>` +
+			`<a href="/docs/stub">stub</a><a href="/docs/stub2">stub2</a></html>`,
+		"/docs/old":          "-> /docs/new",
+		"/docs/again":        "-> /docs/new",
+		"/docs/new":          `<html>new</html>`,
+		"/docs/moved":        "-> /docs/private/page",
+		"/docs/blocked":      "-> /docs/secret",
+		"/docs/stub":         `<html><head><meta http-equiv="refresh" content="0; url=/docs/target"></head></html>`,
+		"/docs/stub2":        `<html><head><meta http-equiv="refresh" content="0; url=/docs/private/other"></head></html>`,
+		"/docs/target":       `<html>target</html>`,
+		"/docs/secret":       `<html>secret</html>`,
+		"/docs/private/page": `<html>private</html>`,
+	})
+
+	out := t.TempDir()
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:       srv.URL + "/docs",
+		OutputDir:      out,
+		MaxConcurrency: 1,
+		Exclude:        []string{"/docs/private/*"},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	u := func(path string) string { return srv.URL + path }
+
+	old := res.Pages[u("/docs/old")]
+	if old == nil || old.RedirectTo != u("/docs/new") || old.SavedAs != u("/docs/new") ||
+		old.Path != filepath.Join(out, URLToFilename(u("/docs/new"))) {
+		t.Errorf("/docs/old: page = %+v, want saved under the redirect target", old)
+	}
+	if got := res.Skipped[u("/docs/again")]; got != "redirect alias of "+u("/docs/new") {
+		t.Errorf("/docs/again skip reason = %q, want redirect alias", got)
+	}
+
+	wantSkips := map[string]string{
+		"/docs/moved":   "redirected to " + u("/docs/private/page") + `, excluded by rule "/docs/private/*"`,
+		"/docs/blocked": "redirected to " + u("/docs/secret") + ", disallowed by robots.txt",
+		"/docs/stub":    "meta refresh to " + u("/docs/target"),
+		"/docs/stub2":   "meta refresh to " + u("/docs/private/other"),
+	}
+	for path, want := range wantSkips {
+		if got := res.Skipped[u(path)]; got != want {
+			t.Errorf("%s skip reason = %q, want %q", path, got, want)
+		}
+	}
+	for _, p := range res.Pages {
+		if strings.Contains(p.SavedAs, "/docs/private/") || strings.Contains(p.SavedAs, "/docs/secret") {
+			t.Errorf("%s saved as excluded URL %s", p.URL, p.SavedAs)
+		}
+	}
+
+	// The meta refresh target is crawled like a link from the stub; an
+	// excluded target is rejected without being fetched.
+	target := res.Pages[u("/docs/target")]
+	if target == nil || target.Path == "" || target.Referrer != u("/docs/stub") {
+		t.Errorf("/docs/target: page = %+v, want saved with the stub as referrer", target)
+	}
+	if got := res.Skipped[u("/docs/private/other")]; !strings.HasPrefix(got, "excluded by rule") {
+		t.Errorf("/docs/private/other skip reason = %q, want excluded by rule", got)
+	}
+	if srv.fetched("/docs/private/other") {
+		t.Error("excluded meta refresh target was fetched")
+	}
+	if len(res.Saved) != 3 {
+		t.Errorf("saved %d files, want 3 (/docs, /docs/new, /docs/target): %v", len(res.Saved), res.Saved)
+	}
+}
+
+func TestRunMetaRobots(t *testing.T) {
+	srv := newSiteServer(t, map[string]string{
+		"/":        `<html><a href="/hub">hub</a><a href="/private" rel="nofollow">private</a></html>`,
+		"/hub":     `<html><head><meta name="robots" content="noindex"></head><a href="/leaf">leaf</a></html>`,
+		"/leaf":    `<html><head><meta name="robots" content="nofollow"></head><a href="/hidden">hidden</a></html>`,
+		"/hidden":  `<html>hidden</html>`,
+		"/private": `<html>private</html>`,
+	})
+
+	res, err := Run(context.Background(), CrawlConfig{StartURL: srv.URL + "/", OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := res.Skipped[srv.URL+"/hub"]; got != "noindex" {
+		t.Errorf("/hub skip reason = %q, want noindex", got)
+	}
+	if p := res.Pages[srv.URL+"/leaf"]; p == nil || p.Path == "" {
+		t.Errorf("/leaf (linked from a noindex page) was not saved: %+v", p)
+	}
+	for _, path := range []string{"/hidden", "/private"} {
+		if p := res.Pages[srv.URL+path]; p != nil {
+			t.Errorf("%s was crawled despite nofollow: %+v", path, p)
+		}
+	}
+}
+
+func TestRunRetryMergesManifest(t *testing.T) {
+	pages := map[string]string{
+		"/docs":   `<html><a href="/docs/a">a</a><a href="/docs/b">b</a></html>`,
+		"/docs/a": "<html>a</html>",
+	}
+	srv := newSiteServer(t, pages)
+	out := t.TempDir()
+	cfg := CrawlConfig{StartURL: srv.URL + "/docs", OutputDir: out, IgnoreRobots: true}
+	res, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if res.Errors[srv.URL+"/docs/b"] == "" {
+		t.Fatalf("/docs/b did not fail: %+v", res.Pages[srv.URL+"/docs/b"])
+	}
+
+	pages["/docs/b"] = "<html>b</html>"
+	cfg.RetryURLs = []string{srv.URL + "/docs/b"}
+	if _, err := Run(context.Background(), cfg); err != nil {
+		t.Fatalf("retry Run: %v", err)
+	}
+
+	entries, err := manifest.Load(filepath.Join(out, manifest.FileName))
+	if err != nil {
+		t.Fatalf("load manifest: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, strings.TrimPrefix(e.URL, srv.URL))
+	}
+	if want := []string{"/docs", "/docs/a", "/docs/b"}; !slices.Equal(got, want) {
+		t.Errorf("manifest URLs = %v, want %v", got, want)
+	}
+}
+
+func TestRunCharset(t *testing.T) {
+	const text = "日本語のページ"
+	encode := func(s string, enc encoding.Encoding) string {
+		out, err := enc.NewEncoder().String(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/docs":
+			fmt.Fprint(w, `<html><a href="/docs/sjis">sjis</a><a href="/docs/euc">euc</a></html>`)
+		case "/docs/sjis":
+			w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+			fmt.Fprint(w, encode("<html><head><title>"+text+"</title></head><body>sjis</body></html>", japanese.ShiftJIS))
+		case "/docs/euc":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, encode(`<html><head><meta charset="euc-jp"><title>`+text+"</title></head><body>euc</body></html>", japanese.EUCJP))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	res, err := Run(context.Background(), CrawlConfig{StartURL: srv.URL + "/docs", OutputDir: t.TempDir(), IgnoreRobots: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for path, want := range map[string]string{"/docs/sjis": "shift_jis", "/docs/euc": "euc-jp"} {
+		p := res.Pages[srv.URL+path]
+		if p == nil || p.Path == "" {
+			t.Errorf("%s not saved: %+v", path, p)
+			continue
+		}
+		if p.Charset != want || p.Title != text {
+			t.Errorf("%s: Charset = %q, Title = %q; want %q and %q", path, p.Charset, p.Title, want, text)
+		}
+		data, err := os.ReadFile(p.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "<title>"+text+"</title>") || strings.Contains(string(data), "euc-jp") {
+			t.Errorf("%s: saved file is not UTF-8 with its declaration updated:\n%s", path, data)
+		}
+	}
+}
+
+func TestRunMultipleSeeds(t *testing.T) {
+	srv := newSiteServer(t, map[string]string{
+		"/docs":      `<html><a href="/docs/a">a</a><a href="/other">other</a></html>`,
+		"/docs/a":    `<html>a</html>`,
+		"/blog":      `<html><a href="/blog/post">post</a></html>`,
+		"/blog/post": `<html>post</html>`,
+		"/other":     `<html>other</html>`,
+	})
+
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:     srv.URL + "/docs",
+		Seeds:        []string{srv.URL + "/blog"},
+		OutputDir:    t.TempDir(),
+		IgnoreRobots: true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Each seed adds its own path prefix to the scope and is recorded as
+	// the seed of the pages found from it.
+	want := map[string]string{
+		"/docs":      "/docs",
+		"/docs/a":    "/docs",
+		"/blog":      "/blog",
+		"/blog/post": "/blog",
+	}
+	for path, seed := range want {
+		p := res.Pages[srv.URL+path]
+		if p == nil || p.Path == "" || p.Seed != srv.URL+seed {
+			t.Errorf("%s: page = %+v, want saved with seed %s", path, p, seed)
+		}
+	}
+	if srv.fetched("/other") {
+		t.Error("/other is outside both seeds' scopes but was fetched")
+	}
+	if len(res.Saved) != len(want) {
+		t.Errorf("Saved = %v, want %d files", res.Saved, len(want))
+	}
+}
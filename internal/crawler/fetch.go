@@ -2,108 +2,360 @@ package crawler
 
 import (
 	"context"
-	"crypto/sha256"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 	"time"
-
-	"golang.org/x/time/rate"
 )
 
-// Fetcher performs rate-limited HTTP GET requests with optional disk caching.
-type Fetcher struct {
+// userAgent is sent with every request.
+const userAgent = "golm-connector/1.0"
+
+// Fetcher retrieves the resources of a crawl. HTTPFetcher fetches http(s)
+// URLs and FileFetcher reads file:// URLs from disk; library users can
+// supply their own through CrawlConfig.Fetcher.
+//
+// Fetch returns failures as *FetchError, with StatusCode set when the
+// resource does not exist or the server refused it.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*Response, error)
+}
+
+// FetcherConfig holds the settings used to build an HTTPFetcher.
+type FetcherConfig struct {
+	// Delay is the minimum interval between requests to one host
+	// (0 = no limit).
+	Delay time.Duration
+	// MaxInFlightPerHost caps concurrent requests to one host (0 = unlimited).
+	MaxInFlightPerHost int
+	// HostLimits override Delay and MaxInFlightPerHost for matching hosts;
+	// the first matching entry wins.
+	HostLimits []HostLimit
+	// AdaptiveDelay raises a host's delay while its latency or error rate
+	// climbs, and eases it back once the host recovers.
+	AdaptiveDelay bool
+	// CacheDir is an optional directory for caching responses ("" = disabled).
+	CacheDir string
+	// CacheTTL is how long cached responses are served without revalidation
+	// (0 = forever).
+	CacheTTL time.Duration
+	// MaxRetries is how many times a transient failure is retried (0 = none).
+	MaxRetries int
+	// RetryBackoff is the base delay for exponential backoff between retries
+	// (0 = 1s).
+	RetryBackoff time.Duration
+
+	// Headers are extra request headers, sent only to AuthHosts.
+	Headers http.Header
+	// BearerToken is sent as "Authorization: Bearer <token>" to AuthHosts.
+	BearerToken string
+	// Username and Password are sent as HTTP basic auth to AuthHosts.
+	Username string
+	Password string
+	// Cookies is an optional cookie jar; its cookies are sent only to AuthHosts.
+	Cookies http.CookieJar
+	// AuthHosts lists the origins (scheme://host[:port]) that may receive
+	// Headers, credentials and cookies.
+	AuthHosts []string
+
+	// Timeout is the limit for a single request, including reading the body
+	// (0 = 30s).
+	Timeout time.Duration
+	// Proxy is an HTTP(S) proxy URL ("" = use HTTP_PROXY/HTTPS_PROXY/NO_PROXY).
+	Proxy string
+	// CACertFiles are PEM files with extra root certificates to trust in
+	// addition to the system pool.
+	CACertFiles []string
+	// ClientCert and ClientKey are PEM files for TLS client authentication.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables TLS certificate verification.
+	InsecureSkipVerify bool
+	// Transport replaces the default HTTP transport, e.g. to serve requests
+	// from a recorded mirror. Proxy and the TLS options are then ignored.
+	Transport http.RoundTripper
+
+	// MaxBodySize is the largest response body accepted, in bytes
+	// (0 = unlimited). Larger responses fail with ErrBodyTooLarge.
+	MaxBodySize int64
+}
+
+// Response is the result of a successful fetch.
+type Response struct {
+	// URL is the requested URL.
+	URL string
+	// FinalURL is the URL after any redirects.
+	FinalURL string
+	// StatusCode is the HTTP status of the (original) response.
+	StatusCode int
+	// Proto is the response protocol, e.g. "HTTP/1.1" ("" for non-HTTP
+	// fetchers).
+	Proto string
+	// Header holds the full response headers.
+	Header http.Header
+	// RequestHeader holds the headers sent with the final request (nil when
+	// the response was served from the cache without a request).
+	RequestHeader http.Header
+	// Body is the response body, or nil when it was spooled to BodyFile.
+	Body []byte
+	// BodyFile is a temporary file holding bodies larger than 8 MiB. The
+	// caller owns it and must Close the response (or move the file away).
+	BodyFile string
+	// Size is the body size in bytes.
+	Size int64
+	// FetchedAt is when the body was downloaded from the origin.
+	FetchedAt time.Time
+	// FromCache is true when Body was served from the disk cache, including
+	// entries revalidated with a 304 Not Modified.
+	FromCache bool
+	// Attempts is the number of requests made (0 for fresh cache hits).
+	Attempts int
+}
+
+// HTTPFetcher performs HTTP GET requests, rate-limited per host, with
+// optional disk caching.
+type HTTPFetcher struct {
 	client   *http.Client
-	limiter  *rate.Limiter
 	cacheDir string
+	cacheTTL time.Duration
+
+	maxRetries   int
+	retryBackoff time.Duration
+	maxBodySize  int64
+
+	headers     http.Header
+	bearerToken string
+	username    string
+	password    string
+	authHosts   map[string]bool
+
+	delay       time.Duration
+	maxInFlight int
+	hostLimits  []HostLimit
+	adaptive    bool
+	hostsMu     sync.Mutex
+	hosts       map[string]*hostState
 }
 
-// NewFetcher creates a Fetcher.
-// delay is the minimum interval between requests (0 = no limit).
-// cacheDir is an optional directory for caching responses ("" = disabled).
-func NewFetcher(delay time.Duration, cacheDir string) *Fetcher {
-	var lim *rate.Limiter
-	if delay > 0 {
-		lim = rate.NewLimiter(rate.Every(delay), 1)
-	} else {
-		lim = rate.NewLimiter(rate.Inf, 0)
-	}
-	return &Fetcher{
+// defaultTimeout is the per-request timeout when FetcherConfig.Timeout is 0.
+const defaultTimeout = 30 * time.Second
+
+// NewHTTPFetcher creates an HTTPFetcher from cfg. It fails when the proxy
+// URL, CA bundles or client certificate cannot be used.
+func NewHTTPFetcher(cfg FetcherConfig) (*HTTPFetcher, error) {
+	transport := cfg.Transport
+	if transport == nil {
+		t, err := newTransport(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("fetcher: %w", err)
+		}
+		transport = t
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	retryBackoff := cfg.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = time.Second
+	}
+	f := &HTTPFetcher{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Transport: transport,
+			Timeout:   timeout,
 		},
-		limiter:  lim,
-		cacheDir: cacheDir,
+		cacheDir:     cfg.CacheDir,
+		cacheTTL:     cfg.CacheTTL,
+		maxRetries:   max(cfg.MaxRetries, 0),
+		retryBackoff: retryBackoff,
+		maxBodySize:  max(cfg.MaxBodySize, 0),
+		headers:      cfg.Headers,
+		bearerToken:  cfg.BearerToken,
+		username:     cfg.Username,
+		password:     cfg.Password,
+		authHosts:    make(map[string]bool, len(cfg.AuthHosts)),
+		delay:        max(cfg.Delay, 0),
+		maxInFlight:  max(cfg.MaxInFlightPerHost, 0),
+		hostLimits:   cfg.HostLimits,
+		adaptive:     cfg.AdaptiveDelay,
+		hosts:        make(map[string]*hostState),
+	}
+	for _, h := range cfg.AuthHosts {
+		f.authHosts[strings.TrimSuffix(strings.ToLower(h), "/")] = true
+	}
+	f.client.CheckRedirect = f.checkRedirect
+	if cfg.Cookies != nil {
+		f.client.Jar = scopedJar{jar: cfg.Cookies, authorized: f.authorized}
+	}
+	return f, nil
+}
+
+// Do fetches rawURL with f and returns the response body bytes and the
+// final URL after any redirects.
+func Do(ctx context.Context, f Fetcher, rawURL string) (data []byte, finalURL string, err error) {
+	resp, err := f.Fetch(ctx, rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Close()
+	data, err = resp.Bytes()
+	if err != nil {
+		return nil, "", fmt.Errorf("read body: %w", err)
 	}
+	return data, resp.FinalURL, nil
 }
 
-// Do fetches rawURL and returns the response body bytes and the final URL
-// after any redirects. It respects the rate limiter and serves from cache
-// when available (cache hits return rawURL as finalURL).
-func (f *Fetcher) Do(ctx context.Context, rawURL string) (data []byte, finalURL string, err error) {
+// Fetch GETs rawURL, respecting its host's rate limiter and in-flight cap.
+// Fresh cache entries are served without a request; stale ones are
+// revalidated with If-None-Match/If-Modified-Since and reused on 304 Not
+// Modified.
+//
+// Transient failures (timeouts, connection resets, 429 and 5xx) are retried
+// up to MaxRetries times with jittered exponential backoff, waiting at least
+// as long as any Retry-After header asks. Failures are returned as
+// *FetchError.
+//
+// Bodies larger than MaxBodySize are rejected, up front when the server
+// sends a Content-Length. Large bodies are streamed to a temporary file
+// (see Response.BodyFile); callers should Close the response when done.
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	var cached *Response
 	if f.cacheDir != "" {
-		if data, err := f.readCache(rawURL); err == nil {
-			slog.Debug("cache hit", "url", rawURL)
-			return data, rawURL, nil
+		if c, err := f.readCache(rawURL); err == nil {
+			if !f.fresh(c) {
+				cached = c
+			} else if err := f.loadCachedBody(rawURL, c); err == nil {
+				slog.Debug("cache hit", "url", rawURL)
+				return c, nil
+			}
 		}
 	}
 
-	if err := f.limiter.Wait(ctx); err != nil {
-		return nil, "", fmt.Errorf("rate limiter: %w", err)
+	for attempt := 1; ; attempt++ {
+		r, err := f.fetchOnce(ctx, rawURL, cached)
+		if err == nil {
+			r.Attempts = attempt
+			return r, nil
+		}
+
+		var fe *FetchError
+		if !errors.As(err, &fe) {
+			fe = &FetchError{URL: rawURL, Err: err, Transient: transientError(err)}
+		}
+		fe.Attempts = attempt
+		if ctx.Err() != nil {
+			fe.Transient = false
+		}
+		if !fe.Transient || attempt > f.maxRetries {
+			return nil, fe
+		}
+
+		if fe.StatusCode == http.StatusTooManyRequests || fe.StatusCode == http.StatusServiceUnavailable {
+			_, h := f.host(rawURL)
+			h.throttle()
+		}
+		wait := max(backoff(f.retryBackoff, attempt), fe.RetryAfter)
+		slog.Info("fetch: retrying", "url", rawURL, "attempt", attempt, "wait", wait, "err", fe.Err)
+		if err := sleep(ctx, wait); err != nil {
+			fe.Transient = false
+			return nil, fe
+		}
+	}
+}
+
+// fetchOnce performs a single (possibly conditional) request. HTTP status
+// failures are returned as *FetchError.
+func (f *HTTPFetcher) fetchOnce(ctx context.Context, rawURL string, cached *Response) (*Response, error) {
+	hostKey, h := f.host(rawURL)
+	release, err := h.acquire(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	slog.Debug("fetching", "url", rawURL)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	req, err := f.newRequest(ctx, rawURL)
 	if err != nil {
-		return nil, "", fmt.Errorf("create request: %w", err)
+		return nil, err
+	}
+	if cached != nil {
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lm := cached.Header.Get("Last-Modified"); lm != "" {
+			req.Header.Set("If-Modified-Since", lm)
+		}
 	}
-	req.Header.Set("User-Agent", "golm-connector/1.0")
 
+	start := time.Now()
 	resp, err := f.client.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("http get: %w", err)
+		h.observe(hostKey, time.Since(start), ctx.Err() == nil, f.adaptive)
+		return nil, fmt.Errorf("http get: %w", err)
 	}
 	defer resp.Body.Close()
+	h.observe(hostKey, time.Since(start), transientStatus(resp.StatusCode), f.adaptive)
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		slog.Debug("cache revalidated", "url", rawURL)
+		if err := f.loadCachedBody(rawURL, cached); err != nil {
+			return nil, fmt.Errorf("read cache: %w", err)
+		}
+		cached.FetchedAt = time.Now().UTC()
+		if err := f.writeCacheMeta(rawURL, cached); err != nil {
+			slog.Warn("cache write failed", "url", rawURL, "err", err)
+		}
+		return cached, nil
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("http %d: %s", resp.StatusCode, rawURL)
+		return nil, &FetchError{
+			URL:        rawURL,
+			StatusCode: resp.StatusCode,
+			Transient:  transientStatus(resp.StatusCode),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Err:        fmt.Errorf("http %d: %s", resp.StatusCode, rawURL),
+		}
+	}
+
+	if f.maxBodySize > 0 && resp.ContentLength > f.maxBodySize {
+		return nil, &FetchError{
+			URL:        rawURL,
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("%w: Content-Length %d exceeds %d bytes", ErrBodyTooLarge, resp.ContentLength, f.maxBodySize),
+		}
 	}
 
-	data, err = io.ReadAll(resp.Body)
+	data, file, size, err := readBody(resp.Body, f.maxBodySize)
+	if errors.Is(err, ErrBodyTooLarge) {
+		return nil, &FetchError{URL: rawURL, StatusCode: resp.StatusCode, Err: err}
+	}
 	if err != nil {
-		return nil, "", fmt.Errorf("read body: %w", err)
+		return nil, fmt.Errorf("read body: %w", err)
 	}
 
-	finalURL = resp.Request.URL.String()
+	r := &Response{
+		URL:           rawURL,
+		FinalURL:      resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		Header:        resp.Header,
+		RequestHeader: resp.Request.Header,
+		Body:          data,
+		BodyFile:      file,
+		Size:          size,
+		FetchedAt:     time.Now().UTC(),
+	}
 
 	if f.cacheDir != "" {
-		if err := f.writeCache(rawURL, data); err != nil {
+		if err := f.writeCache(rawURL, r); err != nil {
 			slog.Warn("cache write failed", "url", rawURL, "err", err)
 		}
 	}
 
-	return data, finalURL, nil
-}
-
-func cacheKey(rawURL string) string {
-	h := sha256.Sum256([]byte(rawURL))
-	return fmt.Sprintf("%x", h)
-}
-
-func (f *Fetcher) cachePath(rawURL string) string {
-	return filepath.Join(f.cacheDir, cacheKey(rawURL))
-}
-
-func (f *Fetcher) readCache(rawURL string) ([]byte, error) {
-	return os.ReadFile(f.cachePath(rawURL))
-}
-
-func (f *Fetcher) writeCache(rawURL string, data []byte) error {
-	if err := os.MkdirAll(f.cacheDir, 0o755); err != nil {
-		return err
-	}
-	return os.WriteFile(f.cachePath(rawURL), data, 0o644)
+	return r, nil
 }
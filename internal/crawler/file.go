@@ -0,0 +1,102 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileFetcher reads file:// URLs from the local filesystem, so a static site
+// build or a wget mirror can be crawled without a web server. A directory
+// resolves to its index.html, and an extension-less path that does not exist
+// falls back to the same path with ".html".
+type FileFetcher struct {
+	// MaxBodySize is the largest file accepted, in bytes (0 = unlimited).
+	MaxBodySize int64
+}
+
+// Fetch reads the file behind rawURL. Missing files fail with a *FetchError
+// whose StatusCode is 404. Directories are reported with a trailing slash in
+// Response.FinalURL so relative links resolve against them.
+func (f *FileFetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &FetchError{URL: rawURL, Attempts: 1, Err: err}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+		return nil, &FetchError{URL: rawURL, Attempts: 1, Err: fmt.Errorf("not a local file URL: %s", rawURL)}
+	}
+
+	final := *u
+	name := localPath(u.Path)
+	info, err := os.Stat(name)
+	switch {
+	case err == nil && info.IsDir():
+		name = filepath.Join(name, "index.html")
+		if !strings.HasSuffix(final.Path, "/") {
+			final.Path += "/"
+		}
+		info, err = os.Stat(name)
+	case errors.Is(err, fs.ErrNotExist) && filepath.Ext(name) == "":
+		if i, herr := os.Stat(name + ".html"); herr == nil {
+			name, info, err = name+".html", i, nil
+		}
+	}
+	if err == nil && info.IsDir() {
+		err = fmt.Errorf("%s is a directory", name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &FetchError{URL: rawURL, StatusCode: http.StatusNotFound, Attempts: 1, Err: fmt.Errorf("file not found: %s", name)}
+	}
+	if err != nil {
+		return nil, &FetchError{URL: rawURL, Attempts: 1, Err: err}
+	}
+	if f.MaxBodySize > 0 && info.Size() > f.MaxBodySize {
+		return nil, &FetchError{URL: rawURL, Attempts: 1,
+			Err: fmt.Errorf("%w: %s is %d bytes, limit %d", ErrBodyTooLarge, name, info.Size(), f.MaxBodySize)}
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, &FetchError{URL: rawURL, Attempts: 1, Err: err}
+	}
+	defer file.Close()
+	data, tmp, size, err := readBody(file, f.MaxBodySize)
+	if err != nil {
+		return nil, &FetchError{URL: rawURL, Attempts: 1, Err: fmt.Errorf("read %s: %w", name, err)}
+	}
+
+	header := make(http.Header)
+	if ct := mime.TypeByExtension(filepath.Ext(name)); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+	header.Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	return &Response{
+		URL:        rawURL,
+		FinalURL:   final.String(),
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       data,
+		BodyFile:   tmp,
+		Size:       size,
+		FetchedAt:  time.Now().UTC(),
+		Attempts:   1,
+	}, nil
+}
+
+// localPath converts the path of a file:// URL to an OS path, dropping the
+// leading slash before a Windows drive letter ("/C:/site" → "C:/site").
+func localPath(p string) string {
+	if len(p) >= 3 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return filepath.FromSlash(p)
+}
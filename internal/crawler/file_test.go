@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeSite creates files (path → content) under a temporary directory and
+// returns its file:// URL.
+func writeSite(t *testing.T, files map[string]string) (string, string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, (&url.URL{Scheme: "file", Path: filepath.ToSlash(root)}).String()
+}
+
+func TestFileFetcher(t *testing.T) {
+	_, base := writeSite(t, map[string]string{
+		"docs/index.html": "<html>docs</html>",
+		"docs/intro.html": "<html>intro</html>",
+		"docs/guide.pdf":  "%PDF-1.4",
+	})
+	f := &FileFetcher{}
+	ctx := context.Background()
+
+	resp, err := f.Fetch(ctx, base+"/docs")
+	if err != nil {
+		t.Fatalf("Fetch dir: %v", err)
+	}
+	if string(resp.Body) != "<html>docs</html>" || resp.FinalURL != base+"/docs/" {
+		t.Errorf("dir: Body = %q, FinalURL = %q", resp.Body, resp.FinalURL)
+	}
+
+	resp, err = f.Fetch(ctx, base+"/docs/intro")
+	if err != nil || string(resp.Body) != "<html>intro</html>" {
+		t.Errorf("extension-less: Body = %q, err = %v", resp.Body, err)
+	}
+
+	resp, err = f.Fetch(ctx, base+"/docs/guide.pdf")
+	if err != nil || resp.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("pdf: Content-Type = %q, err = %v", resp.Header.Get("Content-Type"), err)
+	}
+
+	_, err = f.Fetch(ctx, base+"/missing.html")
+	var fe *FetchError
+	if !errors.As(err, &fe) || fe.StatusCode != http.StatusNotFound {
+		t.Errorf("missing: err = %v, want 404 FetchError", err)
+	}
+}
+
+func TestRunLocalSite(t *testing.T) {
+	_, base := writeSite(t, map[string]string{
+		"site/index.html":       `<html><a href="guide/">Guide</a><a href="about.html">About</a><a href="../outside.html">x</a></html>`,
+		"site/guide/index.html": `<html><a href="../about.html">About</a><a href="https://example.com/">ext</a></html>`,
+		"site/about.html":       `<html>about</html>`,
+		"outside.html":          `<html>outside</html>`,
+	})
+	out := t.TempDir()
+	res, err := Run(context.Background(), CrawlConfig{StartURL: base + "/site/", OutputDir: out})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Pages are saved under their URL path, like HTTP pages.
+	var saved []string
+	for _, p := range res.Saved {
+		saved = append(saved, filepath.ToSlash(p))
+	}
+	slices.Sort(saved)
+	want := []string{"/site.html", "/site/about.html", "/site/guide.html"}
+	if len(saved) != len(want) || len(res.Errors) > 0 {
+		t.Fatalf("saved %v (errors %v), want %d files", saved, res.Errors, len(want))
+	}
+	for i, w := range want {
+		if !strings.HasSuffix(saved[i], w) {
+			t.Errorf("saved[%d] = %s, want suffix %s", i, saved[i], w)
+		}
+	}
+	for u := range res.Pages {
+		if strings.Contains(u, "outside") || strings.Contains(u, "example.com") {
+			t.Errorf("out-of-scope URL crawled: %s", u)
+		}
+	}
+}
+
+// recordingFetcher records the URLs it is asked for.
+type recordingFetcher struct {
+	Fetcher
+	mu   sync.Mutex
+	urls []string
+}
+
+func (f *recordingFetcher) Fetch(ctx context.Context, rawURL string) (*Response, error) {
+	f.mu.Lock()
+	f.urls = append(f.urls, rawURL)
+	f.mu.Unlock()
+	return f.Fetcher.Fetch(ctx, rawURL)
+}
+
+func TestRunLocalSiteSkipsSitemapDiscovery(t *testing.T) {
+	_, base := writeSite(t, map[string]string{
+		"site/index.html": `<html><a href="about.html">About</a></html>`,
+		"site/about.html": `<html>about</html>`,
+	})
+	f := &recordingFetcher{Fetcher: &FileFetcher{}}
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:    base + "/site/",
+		OutputDir:   t.TempDir(),
+		UseSitemaps: true,
+		Fetcher:     f,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(res.Saved) != 2 {
+		t.Errorf("Saved = %v, want 2 files", res.Saved)
+	}
+	for _, u := range f.urls {
+		if !strings.HasPrefix(u, base+"/site") {
+			t.Errorf("fetched %s outside the local site", u)
+		}
+	}
+}
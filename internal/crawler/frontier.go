@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+)
+
+// FrontierOrder selects the order in which queued URLs are crawled.
+type FrontierOrder string
+
+const (
+	// OrderDiscovery crawls URLs in the order they were found (breadth
+	// first from the seeds).
+	OrderDiscovery FrontierOrder = "discovery"
+	// OrderDepth crawls the shallowest queued URLs first, so sitemap URLs
+	// and seeds never wait behind deep links.
+	OrderDepth FrontierOrder = "depth"
+	// OrderPriority crawls the URLs with the highest priority first: the
+	// weight of the first matching URLWeight plus the sitemap <priority>
+	// (0.5 for URLs not listed in a sitemap). Ties are broken by depth.
+	OrderPriority FrontierOrder = "priority"
+)
+
+// defaultSitemapPriority is the priority of URLs without a sitemap entry,
+// as for sitemap entries without <priority>.
+const defaultSitemapPriority = 0.5
+
+// URLWeight raises (or, when negative, lowers) the crawl priority of URLs
+// matching Pattern under OrderPriority.
+type URLWeight struct {
+	// Pattern uses the Include/Exclude rule syntax: a glob, or a regexp with
+	// a "re:" prefix.
+	Pattern string
+	Weight  float64
+}
+
+// urlWeights scores URLs by the first matching weight.
+type urlWeights struct {
+	rules   []urlRule
+	weights []float64
+}
+
+// compileURLWeights compiles the patterns of weights.
+func compileURLWeights(weights []URLWeight) (*urlWeights, error) {
+	w := &urlWeights{}
+	for _, uw := range weights {
+		rule, err := compileRule(uw.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("weight: %w", err)
+		}
+		w.rules = append(w.rules, rule)
+		w.weights = append(w.weights, uw.Weight)
+	}
+	return w, nil
+}
+
+// score returns the weight of the first rule matching rawURL, or 0.
+func (w *urlWeights) score(rawURL string) float64 {
+	for i, rule := range w.rules {
+		if rule.match(rawURL) {
+			return w.weights[i]
+		}
+	}
+	return 0
+}
+
+// frontier is the queue of URLs waiting to be fetched, ordered by a
+// FrontierOrder. Items keep their discovery sequence number, so a URL put
+// back after an interrupted fetch returns to its original place.
+type frontier struct {
+	order FrontierOrder
+	items []queueItem
+	seq   int
+}
+
+func newFrontier(order FrontierOrder) *frontier {
+	return &frontier{order: order}
+}
+
+// push queues it.
+func (f *frontier) push(it queueItem) {
+	if it.seq == 0 {
+		f.seq++
+		it.seq = f.seq
+	}
+	heap.Push((*frontierHeap)(f), it)
+}
+
+// pop removes and returns the next URL to crawl.
+func (f *frontier) pop() queueItem {
+	return heap.Pop((*frontierHeap)(f)).(queueItem)
+}
+
+func (f *frontier) len() int { return len(f.items) }
+
+// snapshot returns the queued items in crawl order.
+func (f *frontier) snapshot() []queueItem {
+	items := slices.Clone(f.items)
+	slices.SortFunc(items, func(a, b queueItem) int {
+		switch {
+		case f.before(a, b):
+			return -1
+		case f.before(b, a):
+			return 1
+		}
+		return 0
+	})
+	return items
+}
+
+// before reports whether a is crawled before b.
+func (f *frontier) before(a, b queueItem) bool {
+	switch f.order {
+	case OrderPriority:
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		fallthrough
+	case OrderDepth:
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+	}
+	return a.seq < b.seq
+}
+
+// frontierHeap implements heap.Interface for frontier.
+type frontierHeap frontier
+
+func (h *frontierHeap) Len() int           { return len(h.items) }
+func (h *frontierHeap) Less(i, j int) bool { return (*frontier)(h).before(h.items[i], h.items[j]) }
+func (h *frontierHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *frontierHeap) Push(x any)         { h.items = append(h.items, x.(queueItem)) }
+func (h *frontierHeap) Pop() any {
+	it := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return it
+}
@@ -0,0 +1,109 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFrontierOrder(t *testing.T) {
+	items := []queueItem{
+		{URL: "a", Depth: 2, Priority: 0.5},
+		{URL: "b", Depth: 1, Priority: 0.5},
+		{URL: "c", Depth: 2, Priority: 1.5},
+		{URL: "d", Depth: 0, Priority: -0.5},
+		{URL: "e", Depth: 1, Priority: 0.5},
+	}
+	tests := []struct {
+		order FrontierOrder
+		want  string
+	}{
+		{OrderDiscovery, "a b c d e"},
+		{OrderDepth, "d b e a c"},
+		{OrderPriority, "c b e a d"},
+	}
+	for _, tc := range tests {
+		f := newFrontier(tc.order)
+		for _, it := range items {
+			f.push(it)
+		}
+		var snap []string
+		for _, it := range f.snapshot() {
+			snap = append(snap, it.URL)
+		}
+		var got []string
+		for f.len() > 0 {
+			got = append(got, f.pop().URL)
+		}
+		if strings.Join(got, " ") != tc.want || strings.Join(snap, " ") != tc.want {
+			t.Errorf("%s: popped %v, snapshot %v, want %s", tc.order, got, snap, tc.want)
+		}
+	}
+}
+
+func TestFrontierRequeueKeepsPlace(t *testing.T) {
+	f := newFrontier(OrderDiscovery)
+	for _, u := range []string{"a", "b", "c"} {
+		f.push(queueItem{URL: u})
+	}
+	first := f.pop()
+	f.push(queueItem{URL: "d"})
+	f.push(first)
+	if got := f.pop().URL; got != "a" {
+		t.Errorf("pop after requeue = %q, want a", got)
+	}
+}
+
+func TestURLWeightsScore(t *testing.T) {
+	w, err := compileURLWeights([]URLWeight{
+		{Pattern: "/docs/guide/*", Weight: 2},
+		{Pattern: "re:/release-notes/", Weight: -1},
+		{Pattern: "/docs/*", Weight: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]float64{
+		"https://example.com/docs/guide/intro":     2,
+		"https://example.com/docs/release-notes/1": -1,
+		"https://example.com/docs/api":             1,
+		"https://example.com/blog/post":            0,
+	}
+	for u, want := range tests {
+		if got := w.score(u); got != want {
+			t.Errorf("score(%s) = %v, want %v", u, got, want)
+		}
+	}
+	if _, err := compileURLWeights([]URLWeight{{Pattern: "re:("}}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestRunPriorityFrontier(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><a href="/blog/1">1</a><a href="/blog/2">2</a><a href="/guide/start">guide</a></html>`)
+			return
+		}
+		fmt.Fprintf(w, "<html>%s</html>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:       srv.URL + "/",
+		OutputDir:      t.TempDir(),
+		IgnoreRobots:   true,
+		MaxPages:       2,
+		MaxConcurrency: 1,
+		URLWeights:     []URLWeight{{Pattern: "/guide/*", Weight: 1}},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if p := res.Pages[srv.URL+"/guide/start"]; p == nil || p.Path == "" {
+		t.Errorf("weighted page was not crawled within the page budget; saved %v", res.Saved)
+	}
+}
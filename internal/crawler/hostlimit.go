@@ -0,0 +1,218 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// adaptiveAlpha is the weight of the newest sample in the moving
+	// averages of latency and errors.
+	adaptiveAlpha = 0.2
+	// adaptiveMinSamples is how many responses are needed before the
+	// adaptive slowdown reacts.
+	adaptiveMinSamples = 5
+	// adaptiveLatencyFactor is how much slower than its baseline a host must
+	// get before it is slowed down.
+	adaptiveLatencyFactor = 2.0
+	// adaptiveErrorRate is the error rate above which a host is slowed down.
+	adaptiveErrorRate = 0.2
+)
+
+// HostLimit overrides the request delay and in-flight cap for hosts matching
+// Pattern.
+type HostLimit struct {
+	// Pattern is a host name glob (path.Match syntax) such as
+	// "api.example.com" or "*.example.com", matched without the port.
+	Pattern string
+	// Delay is the minimum interval between requests to one host
+	// (negative = use FetcherConfig.Delay).
+	Delay time.Duration
+	// MaxInFlight caps concurrent requests to one host
+	// (0 = use FetcherConfig.MaxInFlightPerHost).
+	MaxInFlight int
+}
+
+// matchHost reports whether hostname matches the limit's pattern.
+func (l HostLimit) matchHost(hostname string) bool {
+	ok, err := path.Match(strings.ToLower(l.Pattern), strings.ToLower(hostname))
+	return err == nil && ok
+}
+
+// hostState is the rate limiter, in-flight semaphore and health statistics
+// of one host.
+type hostState struct {
+	limiter *rate.Limiter
+	// slots limits concurrent requests; nil = unlimited.
+	slots chan struct{}
+
+	mu sync.Mutex
+	// base is the configured delay, raised by SlowDown (e.g. for a
+	// robots.txt Crawl-delay); adaptive speed-ups never go below it.
+	base     time.Duration
+	samples  int
+	latency  float64 // moving average, seconds
+	baseline float64 // lowest moving average seen, seconds
+	errRate  float64 // moving average of failures
+}
+
+func newHostState(delay time.Duration, maxInFlight int) *hostState {
+	h := &hostState{base: delay}
+	if delay > 0 {
+		h.limiter = rate.NewLimiter(rate.Every(delay), 1)
+	} else {
+		h.limiter = rate.NewLimiter(rate.Inf, 0)
+	}
+	if maxInFlight > 0 {
+		h.slots = make(chan struct{}, maxInFlight)
+	}
+	return h
+}
+
+// acquire waits for an in-flight slot and the rate limiter. The returned
+// function releases the slot.
+func (h *hostState) acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			release = func() { <-h.slots }
+		case <-ctx.Done():
+			return nil, fmt.Errorf("rate limiter: %w", ctx.Err())
+		}
+	}
+	if err := h.limiter.Wait(ctx); err != nil {
+		release()
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+	return release, nil
+}
+
+// slowDown lowers the request rate so that requests are at least d apart.
+// It never speeds the limiter up.
+func (h *hostState) slowDown(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	if lim := rate.Every(d); lim < h.limiter.Limit() {
+		h.limiter.SetLimit(lim)
+		if h.limiter.Burst() < 1 {
+			h.limiter.SetBurst(1)
+		}
+	}
+}
+
+// interval returns the current minimum delay between requests.
+func (h *hostState) interval() time.Duration {
+	lim := h.limiter.Limit()
+	if lim == rate.Inf || lim <= 0 {
+		return 0
+	}
+	return time.Duration(float64(time.Second) / float64(lim))
+}
+
+// throttle halves the host's request rate after the server pushed back with
+// 429 or 503, down to one request per throttleFloor.
+func (h *hostState) throttle() {
+	cur := h.interval()
+	next := cur * 2
+	if cur == 0 {
+		next = throttleStart
+	}
+	h.slowDown(min(next, throttleFloor))
+}
+
+// observe records the latency and outcome of a request and, when adaptive
+// is set, adjusts the host's delay: it is raised by half when the host gets
+// markedly slower than its baseline or starts failing, and eased back
+// towards the configured delay once the host is healthy again.
+func (h *hostState) observe(host string, latency time.Duration, failed bool, adaptive bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fail := 0.0
+	if failed {
+		fail = 1
+	}
+	secs := latency.Seconds()
+	if h.samples == 0 {
+		h.latency, h.baseline, h.errRate = secs, secs, fail
+	} else {
+		h.latency += adaptiveAlpha * (secs - h.latency)
+		h.errRate += adaptiveAlpha * (fail - h.errRate)
+		h.baseline = min(h.baseline, h.latency)
+	}
+	h.samples++
+	if !adaptive || h.samples < adaptiveMinSamples {
+		return
+	}
+
+	cur := h.interval()
+	slow := h.latency > adaptiveLatencyFactor*h.baseline && h.latency-h.baseline > 0.05
+	switch {
+	case slow || h.errRate > adaptiveErrorRate:
+		next := min(max(cur*3/2, throttleStart/4), throttleFloor)
+		if next > cur {
+			slog.Info("fetch: slowing down host", "host", host, "delay", next,
+				"latency", time.Duration(h.latency*float64(time.Second)), "error_rate", h.errRate)
+			h.slowDown(next)
+		}
+	case cur > h.base && h.errRate < adaptiveErrorRate/4:
+		next := max(cur*9/10, h.base)
+		if next <= 0 {
+			h.limiter.SetLimit(rate.Inf)
+		} else {
+			h.limiter.SetLimit(rate.Every(next))
+		}
+	}
+}
+
+// host returns the state for the host of rawURL, creating it from the first
+// matching HostLimit (or the defaults) on first use.
+func (f *HTTPFetcher) host(rawURL string) (string, *hostState) {
+	key := rawURL
+	hostname := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		key, hostname = strings.ToLower(u.Host), u.Hostname()
+	}
+
+	f.hostsMu.Lock()
+	defer f.hostsMu.Unlock()
+	if h, ok := f.hosts[key]; ok {
+		return key, h
+	}
+	delay, inFlight := f.delay, f.maxInFlight
+	for _, l := range f.hostLimits {
+		if l.matchHost(hostname) {
+			if l.Delay >= 0 {
+				delay = l.Delay
+			}
+			if l.MaxInFlight > 0 {
+				inFlight = l.MaxInFlight
+			}
+			break
+		}
+	}
+	h := newHostState(delay, inFlight)
+	f.hosts[key] = h
+	return key, h
+}
+
+// SlowDown lowers the request rate for the host of rawURL so that requests
+// are at least d apart for the rest of the crawl. It never speeds the
+// limiter up.
+func (f *HTTPFetcher) SlowDown(rawURL string, d time.Duration) {
+	_, h := f.host(rawURL)
+	h.mu.Lock()
+	h.base = max(h.base, d)
+	h.mu.Unlock()
+	h.slowDown(d)
+}
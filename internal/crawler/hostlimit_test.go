@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimitMatch(t *testing.T) {
+	tests := []struct {
+		pattern, host string
+		want          bool
+	}{
+		{"api.example.com", "api.example.com", true},
+		{"api.example.com", "API.Example.com", true},
+		{"*.example.com", "docs.example.com", true},
+		{"*.example.com", "example.com", false},
+		{"example.com", "docs.example.com", false},
+	}
+	for _, tc := range tests {
+		if got := (HostLimit{Pattern: tc.pattern}).matchHost(tc.host); got != tc.want {
+			t.Errorf("matchHost(%q, %q) = %v, want %v", tc.pattern, tc.host, got, tc.want)
+		}
+	}
+}
+
+func TestFetcherHostOverrides(t *testing.T) {
+	f := mustFetcher(t, FetcherConfig{
+		Delay:              time.Second,
+		MaxInFlightPerHost: 4,
+		HostLimits: []HostLimit{
+			{Pattern: "api.example.com", Delay: 5 * time.Second, MaxInFlight: 1},
+			{Pattern: "*.example.com", Delay: -1, MaxInFlight: 2},
+		},
+	})
+
+	_, api := f.host("https://api.example.com:8443/v1")
+	_, docs := f.host("https://docs.example.com/")
+	_, other := f.host("https://other.org/")
+	if api.interval() != 5*time.Second || cap(api.slots) != 1 {
+		t.Errorf("api: interval %v, slots %d; want 5s, 1", api.interval(), cap(api.slots))
+	}
+	if docs.interval() != time.Second || cap(docs.slots) != 2 {
+		t.Errorf("docs: interval %v, slots %d; want 1s, 2", docs.interval(), cap(docs.slots))
+	}
+	if other.interval() != time.Second || cap(other.slots) != 4 {
+		t.Errorf("other: interval %v, slots %d; want 1s, 4", other.interval(), cap(other.slots))
+	}
+
+	f.SlowDown("https://docs.example.com/x", 3*time.Second)
+	if docs.interval() != 3*time.Second || other.interval() != time.Second {
+		t.Errorf("SlowDown affected the wrong hosts: docs %v, other %v", docs.interval(), other.interval())
+	}
+}
+
+func TestFetchMaxInFlightPerHost(t *testing.T) {
+	var cur, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := cur.Add(1)
+		defer cur.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{MaxInFlightPerHost: 2})
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := f.Fetch(context.Background(), srv.URL); err != nil {
+				t.Errorf("Fetch: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak in-flight requests = %d, want <= 2", p)
+	}
+}
+
+func TestAdaptiveSlowdown(t *testing.T) {
+	h := newHostState(0, 0)
+	for range adaptiveMinSamples {
+		h.observe("example.com", 10*time.Millisecond, false, true)
+	}
+	if h.interval() != 0 {
+		t.Fatalf("healthy host slowed down to %v", h.interval())
+	}
+
+	for range 3 {
+		h.observe("example.com", 10*time.Millisecond, true, true)
+	}
+	slowed := h.interval()
+	if slowed == 0 {
+		t.Fatal("failing host was not slowed down")
+	}
+
+	for range 50 {
+		h.observe("example.com", 10*time.Millisecond, false, true)
+	}
+	if got := h.interval(); got >= slowed {
+		t.Errorf("recovered host interval = %v, want below %v", got, slowed)
+	}
+}
+
+func TestSlowDownSurvivesAdaptiveEaseBack(t *testing.T) {
+	f := mustFetcher(t, FetcherConfig{Delay: 100 * time.Millisecond, AdaptiveDelay: true})
+	f.SlowDown("https://example.com/robots.txt", 10*time.Second)
+	_, h := f.host("https://example.com/docs")
+	for range 100 {
+		h.observe("example.com", 10*time.Millisecond, false, true)
+	}
+	if got := h.interval(); got < 10*time.Second {
+		t.Errorf("interval after healthy responses = %v, want at least the 10s Crawl-delay", got)
+	}
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"golm-connector/internal/manifest"
+)
+
+// Change values recorded in PageInfo.Change by incremental crawls.
+const (
+	ChangeAdded     = "added"
+	ChangeChanged   = "changed"
+	ChangeUnchanged = "unchanged"
+	ChangeRemoved   = "removed"
+)
+
+// previousRun is the manifest of the previous crawl into the same output
+// directory, indexed by URL.
+type previousRun struct {
+	outputDir string
+	entries   []manifest.Entry
+	byURL     map[string]manifest.Entry
+}
+
+// loadPreviousEntries reads the manifest left in outputDir by the previous
+// crawl. A missing manifest (first run) yields no entries.
+func loadPreviousEntries(outputDir string) ([]manifest.Entry, error) {
+	entries, err := manifest.Load(filepath.Join(outputDir, manifest.FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+func newPreviousRun(outputDir string, entries []manifest.Entry) *previousRun {
+	p := &previousRun{outputDir: outputDir, entries: entries, byURL: make(map[string]manifest.Entry, len(entries))}
+	for _, e := range entries {
+		p.byURL[e.URL] = e
+	}
+	return p
+}
+
+// path returns the file e was saved to.
+func (p *previousRun) path(e manifest.Entry) string {
+	return filepath.Join(p.outputDir, filepath.FromSlash(e.Path))
+}
+
+// change classifies the body with the given hash that is about to be saved
+// for rawURL at outPath. Unchanged bodies need not be written again.
+func (p *previousRun) change(rawURL, hash, outPath string) string {
+	e, ok := p.byURL[rawURL]
+	if !ok {
+		return ChangeAdded
+	}
+	if e.Hash == hash && p.path(e) == outPath {
+		if _, err := os.Stat(outPath); err == nil {
+			return ChangeUnchanged
+		}
+	}
+	return ChangeChanged
+}
+
+// markRemoved records the previously saved pages this crawl did not save
+// again as removed, deleting their files when prune is set. Pages that
+// failed with a transient error keep their previous file.
+func (p *previousRun) markRemoved(res *CrawlResult, prune bool) {
+	kept := make(map[string]bool, len(res.Pages))
+	for _, page := range res.Pages {
+		if page.Path != "" {
+			kept[page.Path] = true
+		}
+	}
+
+	for _, e := range p.entries {
+		page := res.Pages[e.URL]
+		path := p.path(e)
+		if (page != nil && page.Path != "") || kept[path] {
+			continue
+		}
+		if page != nil && page.ErrorClass == ClassTransient {
+			page.Path, page.Hash, page.Title, page.ContentType = path, e.Hash, e.Title, e.ContentType
+			page.Change = ChangeUnchanged
+			kept[path] = true
+			continue
+		}
+		if page == nil {
+			page = &PageInfo{URL: e.URL, Depth: e.Depth, Seed: e.Seed, Referrer: e.Referrer}
+			res.Pages[e.URL] = page
+			res.Skipped[e.URL] = "no longer found by the crawl"
+		}
+		page.Change = ChangeRemoved
+		slog.Info("crawl: removed", "url", e.URL, "path", path, "pruned", prune)
+		if prune {
+			if err := pruneFile(p.outputDir, path); err != nil {
+				slog.Warn("crawl: prune failed", "path", path, "err", err)
+			}
+		}
+	}
+}
+
+// pruneFile deletes path and then any parent directories below root that
+// it leaves empty.
+func pruneFile(root, path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(path); dir != root && len(dir) > len(root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
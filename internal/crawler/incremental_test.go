@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunIncremental(t *testing.T) {
+	pages := map[string]string{
+		"/":  `<html><a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></html>`,
+		"/a": "<html>a v1</html>",
+		"/b": "<html>b</html>",
+		"/c": "<html>c</html>",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	out := t.TempDir()
+	cfg := CrawlConfig{StartURL: srv.URL + "/", OutputDir: out, IgnoreRobots: true, Incremental: true, Prune: true}
+	res, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+	if got := res.Pages[srv.URL+"/a"].Change; got != ChangeAdded {
+		t.Errorf("first run: /a change = %q, want %q", got, ChangeAdded)
+	}
+
+	// Mark /b's file so a rewrite would be noticed.
+	bPath := res.Pages[srv.URL+"/b"].Path
+	if err := os.WriteFile(bPath, []byte("local"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pages["/"] = `<html><a href="/a">a</a><a href="/b">b</a><a href="/d">d</a></html>`
+	pages["/a"] = "<html>a v2</html>"
+	pages["/d"] = "<html>d</html>"
+	delete(pages, "/c")
+	cPath := res.Pages[srv.URL+"/c"].Path
+
+	res, err = Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	want := map[string]string{
+		"/":  ChangeChanged,
+		"/a": ChangeChanged,
+		"/b": ChangeUnchanged,
+		"/c": ChangeRemoved,
+		"/d": ChangeAdded,
+	}
+	for path, change := range want {
+		p := res.Pages[srv.URL+path]
+		if p == nil || p.Change != change {
+			t.Errorf("%s: page = %+v, want change %q", path, p, change)
+		}
+	}
+	if data, _ := os.ReadFile(bPath); string(data) != "local" {
+		t.Errorf("unchanged page was rewritten: %q", data)
+	}
+	if _, err := os.Stat(cPath); !os.IsNotExist(err) {
+		t.Errorf("removed page %s not pruned (err %v)", filepath.Base(cPath), err)
+	}
+}
+
+func TestRunIncrementalRetryKeepsPages(t *testing.T) {
+	srv := newSiteServer(t, map[string]string{
+		"/":  `<html><a href="/a">a</a><a href="/b">b</a></html>`,
+		"/a": "<html>a</html>",
+		"/b": "<html>b</html>",
+	})
+
+	out := t.TempDir()
+	cfg := CrawlConfig{StartURL: srv.URL + "/", OutputDir: out, IgnoreRobots: true, Incremental: true, Prune: true}
+	first, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("first Run: %v", err)
+	}
+
+	// A retry run only visits the retried URL: the pages it did not see
+	// must be neither marked removed nor pruned.
+	cfg.RetryURLs = []string{srv.URL + "/b"}
+	res, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("retry Run: %v", err)
+	}
+	for _, path := range []string{"/", "/a", "/b"} {
+		if p := res.Pages[srv.URL+path]; p != nil && p.Change == ChangeRemoved {
+			t.Errorf("%s marked removed by a retry run", path)
+		}
+		if _, err := os.Stat(first.Pages[srv.URL+path].Path); err != nil {
+			t.Errorf("%s: saved file gone after retry run: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxBackoff caps the exponential backoff between attempts.
+	maxBackoff = 30 * time.Second
+	// maxRetryAfter caps how long a Retry-After header can make us wait.
+	maxRetryAfter = 5 * time.Minute
+	// throttleFloor is the slowest rate the limiter is reduced to by throttle.
+	throttleFloor = 30 * time.Second
+	// throttleStart is the interval used when throttling an unlimited limiter.
+	throttleStart = time.Second
+)
+
+// Error classifications recorded in CrawlResult and the report.
+const (
+	ClassTransient = "transient"
+	ClassPermanent = "permanent"
+)
+
+// FetchError describes a failed fetch after all attempts were used.
+type FetchError struct {
+	// URL is the requested URL.
+	URL string
+	// StatusCode is the HTTP status, or 0 for network errors.
+	StatusCode int
+	// Attempts is the number of requests made.
+	Attempts int
+	// Transient reports whether the failure was considered retryable.
+	Transient bool
+	// RetryAfter is the server-requested delay from the last response.
+	RetryAfter time.Duration
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+	}
+	return e.Err.Error()
+}
+
+func (e *FetchError) Unwrap() error { return e.Err }
+
+// Class returns ClassTransient or ClassPermanent.
+func (e *FetchError) Class() string {
+	if e.Transient {
+		return ClassTransient
+	}
+	return ClassPermanent
+}
+
+// transientStatus reports whether an HTTP status is worth retrying.
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || (code >= 500 && code != http.StatusNotImplemented)
+}
+
+// transientError reports whether a network error is worth retrying:
+// timeouts, connection resets and truncated responses.
+func transientError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter parses a Retry-After header given either as seconds or as
+// an HTTP date. It returns 0 when the header is absent or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	}
+	if d < 0 {
+		return 0
+	}
+	return min(d, maxRetryAfter)
+}
+
+// backoff returns the jittered delay before retry number attempt (1-based):
+// base·2^(attempt-1), capped at maxBackoff, with "equal jitter" so the delay
+// lies in [d/2, d).
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, maxBackoff)
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"7", 7 * time.Second},
+		{"garbage", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"86400", maxRetryAfter},
+	}
+	for _, tc := range tests {
+		if got := parseRetryAfter(tc.value, now); got != tc.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		full := min(time.Second<<(attempt-1), maxBackoff)
+		got := backoff(time.Second, attempt)
+		if got < full/2 || got >= full {
+			t.Errorf("backoff(1s, %d) = %v, want in [%v, %v)", attempt, got, full/2, full)
+		}
+	}
+}
+
+func TestFetchRetriesTransient(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{MaxRetries: 3, RetryBackoff: time.Millisecond})
+	resp, err := f.Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if resp.Attempts != 3 || string(resp.Body) != "ok" {
+		t.Errorf("Attempts = %d, Body = %q; want 3, \"ok\"", resp.Attempts, resp.Body)
+	}
+}
+
+func TestFetchPermanentNotRetried(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{MaxRetries: 3, RetryBackoff: time.Millisecond})
+	_, err := f.Fetch(context.Background(), srv.URL)
+
+	var fe *FetchError
+	if !errors.As(err, &fe) {
+		t.Fatalf("err = %v, want *FetchError", err)
+	}
+	if fe.Class() != ClassPermanent || fe.Attempts != 1 || calls != 1 {
+		t.Errorf("class = %s, attempts = %d, calls = %d; want permanent, 1, 1", fe.Class(), fe.Attempts, calls)
+	}
+}
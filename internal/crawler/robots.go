@@ -0,0 +1,340 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsAgent is the product token matched against robots.txt User-agent lines.
+const robotsAgent = "golm-connector"
+
+// maxRobotsSize caps how much of a robots.txt file is parsed (RFC 9309 §2.5).
+const maxRobotsSize = 500 * 1024
+
+// robotsRule is a single Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsRules holds the rules of the robots.txt group(s) that apply to us.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	// disallowAll is set when robots.txt was unreachable (RFC 9309 §2.3.1.4).
+	disallowAll bool
+	// sitemaps lists Sitemap: URLs, which apply regardless of user agent.
+	sitemaps []string
+}
+
+// parseRobots parses a robots.txt body and returns the rules that apply to
+// agent. Groups naming agent take precedence over the "*" group; several
+// matching groups are merged.
+func parseRobots(data []byte, agent string) *robotsRules {
+	type group struct {
+		agents []string
+		rules  []robotsRule
+		delay  time.Duration
+	}
+
+	var groups []*group
+	var cur *group
+	var sitemaps []string
+	lastWasAgent := false
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "user-agent":
+			if cur == nil || !lastWasAgent {
+				cur = &group{}
+				groups = append(groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(val))
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if cur == nil || val == "" {
+				// An empty Disallow allows everything; nothing to record.
+				continue
+			}
+			cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: val})
+		case "sitemap":
+			// Sitemap lines are not part of any group.
+			if val != "" {
+				sitemaps = append(sitemaps, val)
+			}
+		case "crawl-delay":
+			lastWasAgent = false
+			if cur == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(val, 64); err == nil && secs > 0 {
+				cur.delay = time.Duration(secs * float64(time.Second))
+			}
+		default:
+			lastWasAgent = false
+		}
+	}
+
+	agent = strings.ToLower(agent)
+	matches := func(want string) []*group {
+		var out []*group
+		for _, g := range groups {
+			for _, a := range g.agents {
+				// Compare product tokens only: "golm-connector/1.0" matches "golm-connector".
+				token, _, _ := strings.Cut(a, "/")
+				if token == want {
+					out = append(out, g)
+					break
+				}
+			}
+		}
+		return out
+	}
+
+	selected := matches(agent)
+	if len(selected) == 0 {
+		selected = matches("*")
+	}
+
+	r := &robotsRules{sitemaps: sitemaps}
+	for _, g := range selected {
+		r.rules = append(r.rules, g.rules...)
+		if g.delay > r.crawlDelay {
+			r.crawlDelay = g.delay
+		}
+	}
+	return r
+}
+
+// Allowed reports whether rawURL may be fetched. The longest matching
+// pattern wins; on a tie Allow beats Disallow.
+func (r *robotsRules) Allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	p := u.EscapedPath()
+	if p == "" {
+		p = "/"
+	}
+	if p == "/robots.txt" {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+
+	best := -1
+	allowed := true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, p) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > best || (n == best && rule.allow) {
+			best = n
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// robotsMatch reports whether path matches a robots.txt pattern, where "*"
+// matches any sequence of characters and a trailing "$" anchors the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	if len(parts) == 1 {
+		return !anchored || pos == len(path)
+	}
+
+	for i := 1; i < len(parts)-1; i++ {
+		idx := strings.Index(path[pos:], parts[i])
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(parts[i])
+	}
+
+	last := parts[len(parts)-1]
+	if anchored {
+		return len(path)-pos >= len(last) && strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path[pos:], last)
+}
+
+// robotsCache fetches and caches robots.txt rules per scheme+host.
+type robotsCache struct {
+	fetcher Fetcher
+	// ignoreDelay leaves Crawl-delay unapplied, for a cache that only
+	// supplies Sitemap: lines to a crawl that ignores robots.txt.
+	ignoreDelay bool
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	once  sync.Once
+	rules *robotsRules
+}
+
+func newRobotsCache(f Fetcher) *robotsCache {
+	return &robotsCache{fetcher: f, hosts: make(map[string]*robotsEntry)}
+}
+
+// Allowed reports whether rawURL may be fetched according to its host's
+// robots.txt, fetching and parsing the file on first use. Any Crawl-delay is
+// applied to the host's rate limiter.
+func (c *robotsCache) Allowed(ctx context.Context, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		// robots.txt only governs HTTP crawlers.
+		return true
+	}
+	return c.rules(ctx, u.Scheme+"://"+u.Host).Allowed(rawURL)
+}
+
+// Sitemaps returns the Sitemap: URLs of origin's robots.txt, or the
+// conventional /sitemap.xml location when none are declared or the file
+// could not be read.
+func (c *robotsCache) Sitemaps(ctx context.Context, origin string) []string {
+	if maps := c.rules(ctx, origin).sitemaps; len(maps) > 0 {
+		return maps
+	}
+	return []string{origin + "/sitemap.xml"}
+}
+
+// rules returns the robots.txt rules of origin ("scheme://host"), loading
+// them on first use.
+func (c *robotsCache) rules(ctx context.Context, origin string) *robotsRules {
+	c.mu.Lock()
+	e, ok := c.hosts[origin]
+	if !ok {
+		e = &robotsEntry{}
+		c.hosts[origin] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() {
+		e.rules = c.load(ctx, origin)
+		if e.rules.crawlDelay > 0 && !c.ignoreDelay {
+			slog.Info("robots: crawl-delay", "origin", origin, "delay", e.rules.crawlDelay)
+			if s, ok := c.fetcher.(slower); ok {
+				s.SlowDown(origin+"/", e.rules.crawlDelay)
+			}
+		}
+	})
+	return e.rules
+}
+
+// load fetches robots.txt for origin. A 4xx response allows everything; a
+// 5xx response or network error disallows everything (RFC 9309 §2.3.1).
+func (c *robotsCache) load(ctx context.Context, origin string) *robotsRules {
+	robotsURL := origin + "/robots.txt"
+	status, data, err := fetchRobots(ctx, c.fetcher, robotsURL)
+	switch {
+	case err != nil:
+		slog.Warn("robots: unreachable, disallowing host", "url", robotsURL, "err", err)
+		return &robotsRules{disallowAll: true}
+	case status >= 500:
+		slog.Warn("robots: server error, disallowing host", "url", robotsURL, "status", status)
+		return &robotsRules{disallowAll: true}
+	case status >= 400:
+		slog.Debug("robots: not found", "url", robotsURL, "status", status)
+		return &robotsRules{}
+	}
+	slog.Debug("robots: loaded", "url", robotsURL, "bytes", len(data))
+	return parseRobots(data, robotsAgent)
+}
+
+// slower is implemented by fetchers that can honor a Crawl-delay.
+type slower interface {
+	SlowDown(rawURL string, d time.Duration)
+}
+
+// fetchRobots returns the status code and body of a robots.txt URL. Missing
+// files are reported by status, not as errors.
+func fetchRobots(ctx context.Context, f Fetcher, robotsURL string) (int, []byte, error) {
+	if h, ok := f.(*HTTPFetcher); ok {
+		return h.fetchRobots(ctx, robotsURL)
+	}
+	data, _, err := Do(ctx, f, robotsURL)
+	var fe *FetchError
+	if errors.As(err, &fe) && fe.StatusCode != 0 {
+		return fe.StatusCode, nil, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, data, nil
+}
+
+// fetchRobots GETs a robots.txt URL, returning the status code and body.
+// Unlike Fetch it does not treat non-200 responses as errors and never
+// caches.
+func (f *HTTPFetcher) fetchRobots(ctx context.Context, robotsURL string) (int, []byte, error) {
+	_, h := f.host(robotsURL)
+	release, err := h.acquire(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer release()
+	req, err := f.newRequest(ctx, robotsURL)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("http get: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return 0, nil, fmt.Errorf("read body: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsGroups(t *testing.T) {
+	const robotsTxt = `# comment
+User-agent: *
+Disallow: /private/
+
+User-agent: Golm-Connector/1.0
+User-agent: otherbot
+Disallow: /search
+Allow: /search/help
+Crawl-delay: 2.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+	r := parseRobots([]byte(robotsTxt), robotsAgent)
+
+	if r.crawlDelay != 2500*time.Millisecond {
+		t.Errorf("crawlDelay = %v, want 2.5s", r.crawlDelay)
+	}
+	if len(r.sitemaps) != 1 || r.sitemaps[0] != "https://example.com/sitemap.xml" {
+		t.Errorf("sitemaps = %v", r.sitemaps)
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		// The specific group replaces "*", so /private/ is allowed for us.
+		{"https://example.com/private/page", true},
+		{"https://example.com/search", false},
+		{"https://example.com/search?q=x", false},
+		{"https://example.com/search/help", true},
+		{"https://example.com/robots.txt", true},
+	}
+	for _, tc := range tests {
+		if got := r.Allowed(tc.url); got != tc.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestParseRobotsWildcardFallback(t *testing.T) {
+	const robotsTxt = `User-agent: somebot
+Disallow: /
+
+User-agent: *
+Disallow: /*.pdf$
+Disallow: /tmp
+Disallow:
+`
+	r := parseRobots([]byte(robotsTxt), robotsAgent)
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/docs/intro", true},
+		{"https://example.com/docs/manual.pdf", false},
+		{"https://example.com/docs/manual.pdf?x=1", true},
+		{"https://example.com/tmp/file", false},
+		{"https://example.com/tmpl", false},
+	}
+	for _, tc := range tests {
+		if got := r.Allowed(tc.url); got != tc.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestRobotsMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/", "/anything", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish$", "/fish", true},
+		{"/fish$", "/fish/", false},
+		{"/*.php", "/index.php?x=1", true},
+		{"/*.php$", "/index.php?x=1", false},
+		{"/a*b*c", "/axxbyyc", true},
+		{"/a*b*c", "/axxcyyb", false},
+		{"*/print", "/docs/print", true},
+	}
+	for _, tc := range tests {
+		if got := robotsMatch(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("robotsMatch(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestRobotsDisallowAll(t *testing.T) {
+	r := &robotsRules{disallowAll: true}
+	if r.Allowed("https://example.com/docs") {
+		t.Error("disallowAll should block pages")
+	}
+	if !r.Allowed("https://example.com/robots.txt") {
+		t.Error("robots.txt itself should always be allowed")
+	}
+}
+
+func TestRunCrawlDelay(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nCrawl-delay: %g\n", delay.Seconds())
+			return
+		case "/":
+			for i := range 11 {
+				fmt.Fprintf(w, `<a href="/p%d">p</a>`, i)
+			}
+		}
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		fmt.Fprintf(w, "<html>%s</html>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	// Fast, healthy responses must not let the adaptive delay ease back
+	// below the Crawl-delay.
+	_, err := Run(context.Background(), CrawlConfig{
+		StartURL:       srv.URL + "/",
+		OutputDir:      t.TempDir(),
+		MaxConcurrency: 1,
+		AdaptiveDelay:  true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(times) != 12 {
+		t.Fatalf("fetched %d pages, want 12", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		// Allow for the server seeing requests somewhat closer together
+		// than the client sent them.
+		if gap := times[i].Sub(times[i-1]); gap < delay*3/4 {
+			t.Errorf("request %d came %v after the previous one, want at least %v", i, gap, delay)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a rule as a regular expression rather than a glob.
+const regexPrefix = "re:"
+
+// urlRule is a compiled include or exclude pattern.
+type urlRule struct {
+	raw string
+	re  *regexp.Regexp
+	// pathOnly rules are matched against path+query instead of the full URL.
+	pathOnly bool
+}
+
+// compileRule compiles a single rule.
+//
+// A rule prefixed with "re:" is a regular expression searched for anywhere
+// in the full URL. Any other rule is a glob where "*" matches any run of
+// characters (including "/"); all other characters, "?" included, are
+// literal. Globs starting with "/" are matched against the URL's
+// path+query, others against the full URL. Globs must match entirely.
+func compileRule(raw string) (urlRule, error) {
+	if expr, ok := strings.CutPrefix(raw, regexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return urlRule{}, fmt.Errorf("rule %q: %w", raw, err)
+		}
+		return urlRule{raw: raw, re: re}, nil
+	}
+	if raw == "" {
+		return urlRule{}, fmt.Errorf("empty rule")
+	}
+
+	parts := strings.Split(raw, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	re := regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+	return urlRule{raw: raw, re: re, pathOnly: strings.HasPrefix(raw, "/")}, nil
+}
+
+func (r urlRule) match(rawURL string) bool {
+	if !r.pathOnly {
+		return r.re.MatchString(rawURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	p := u.EscapedPath()
+	if u.RawQuery != "" {
+		p += "?" + u.RawQuery
+	}
+	return r.re.MatchString(p)
+}
+
+// scopeRules filters in-scope URLs by user-supplied include/exclude rules.
+type scopeRules struct {
+	include []urlRule
+	exclude []urlRule
+}
+
+// compileScopeRules compiles the include and exclude rule lists.
+func compileScopeRules(include, exclude []string) (*scopeRules, error) {
+	r := &scopeRules{}
+	for _, raw := range include {
+		rule, err := compileRule(raw)
+		if err != nil {
+			return nil, fmt.Errorf("include: %w", err)
+		}
+		r.include = append(r.include, rule)
+	}
+	for _, raw := range exclude {
+		rule, err := compileRule(raw)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+		r.exclude = append(r.exclude, rule)
+	}
+	return r, nil
+}
+
+// Check returns "" when rawURL passes the rules, or a reason naming the
+// rule that rejected it. Exclude rules win over include rules; when any
+// include rule is configured, a URL must match at least one.
+func (r *scopeRules) Check(rawURL string) string {
+	for _, rule := range r.exclude {
+		if rule.match(rawURL) {
+			return fmt.Sprintf("excluded by rule %q", rule.raw)
+		}
+	}
+	if len(r.include) == 0 {
+		return ""
+	}
+	for _, rule := range r.include {
+		if rule.match(rawURL) {
+			return ""
+		}
+	}
+	return "not matched by any include rule"
+}
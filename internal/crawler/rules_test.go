@@ -0,0 +1,46 @@
+package crawler
+
+import "testing"
+
+func TestScopeRulesCheck(t *testing.T) {
+	rules, err := compileScopeRules(
+		[]string{"/docs/*"},
+		[]string{"/docs/search*", "*/tags/*", `re:[?&]page=\d+`},
+	)
+	if err != nil {
+		t.Fatalf("compileScopeRules: %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/docs/intro", ""},
+		{"https://example.com/docs/search", `excluded by rule "/docs/search*"`},
+		{"https://example.com/docs/tags/go", `excluded by rule "*/tags/*"`},
+		{"https://example.com/docs/list?page=2", `excluded by rule "re:[?&]page=\\d+"`},
+		{"https://example.com/docs/list?sort=asc", ""},
+		{"https://example.com/blog/post", "not matched by any include rule"},
+	}
+	for _, tc := range tests {
+		if got := rules.Check(tc.url); got != tc.want {
+			t.Errorf("Check(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestScopeRulesEmpty(t *testing.T) {
+	rules, err := compileScopeRules(nil, nil)
+	if err != nil {
+		t.Fatalf("compileScopeRules: %v", err)
+	}
+	if got := rules.Check("https://example.com/anything"); got != "" {
+		t.Errorf("Check with no rules = %q, want empty", got)
+	}
+}
+
+func TestCompileRuleInvalidRegexp(t *testing.T) {
+	if _, err := compileScopeRules(nil, []string{"re:("}); err == nil {
+		t.Error("expected error for invalid regexp")
+	}
+}
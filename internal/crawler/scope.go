@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// ScopeMode selects which hosts a crawl may follow links to.
+type ScopeMode string
+
+const (
+	// ScopeHost keeps the crawl on each seed's exact host and path prefix.
+	ScopeHost ScopeMode = "host"
+	// ScopeDomain also follows the seed's path prefix on every host of the
+	// same registrable domain (example.com, www.example.com,
+	// api.example.com, ...).
+	ScopeDomain ScopeMode = "domain"
+	// ScopeHosts adds the hosts and path prefixes listed in
+	// CrawlConfig.AllowedHosts to the seeds' exact-host scopes.
+	ScopeHosts ScopeMode = "hosts"
+)
+
+// scopeEntry is one seed or allowed host: URLs on a matching host whose
+// path lies below prefix are in scope.
+type scopeEntry struct {
+	// name is reported as the page's seed.
+	name string
+	// host is the lower-cased host[:port], a path.Match pattern when glob
+	// is set.
+	host string
+	glob bool
+	// domain is the registrable domain matched instead of host ("" = host).
+	domain string
+	prefix string
+}
+
+func (e scopeEntry) matches(u *url.URL) bool {
+	if e.domain != "" {
+		if registrableDomain(u.Hostname()) != e.domain {
+			return false
+		}
+	} else if e.glob {
+		if ok, err := path.Match(e.host, strings.ToLower(u.Host)); err != nil || !ok {
+			return false
+		}
+	} else if e.host != strings.ToLower(u.Host) {
+		return false
+	}
+	return pathInScope(e.prefix, u.Path)
+}
+
+// registrableDomain returns the registrable domain (eTLD+1) of hostname,
+// or hostname itself for IP addresses and names such as "localhost".
+func registrableDomain(hostname string) string {
+	hostname = strings.ToLower(hostname)
+	if net.ParseIP(hostname) != nil {
+		return hostname
+	}
+	if d, err := publicsuffix.EffectiveTLDPlusOne(hostname); err == nil {
+		return d
+	}
+	return hostname
+}
+
+// scope is the union of the scopes of all seed URLs (and, in ScopeHosts
+// mode, the allowed hosts): a URL is in scope when it is within at least
+// one of them.
+type scope struct {
+	seeds   []string
+	entries []scopeEntry
+}
+
+// newScope builds the scope of seeds for mode. allowed lists extra
+// "host[:port][/path-prefix]" entries for ScopeHosts; hosts may use glob
+// patterns such as "*.example.com".
+func newScope(seeds []string, mode ScopeMode, allowed []string) (*scope, error) {
+	switch mode {
+	case "", ScopeHost, ScopeDomain, ScopeHosts:
+	default:
+		return nil, fmt.Errorf("unknown scope mode %q (want %s, %s or %s)", mode, ScopeHost, ScopeDomain, ScopeHosts)
+	}
+	if len(allowed) > 0 && mode != ScopeHosts {
+		return nil, fmt.Errorf("allowed hosts require scope mode %q", ScopeHosts)
+	}
+
+	sc := &scope{seeds: seeds}
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			continue
+		}
+		e := scopeEntry{name: seed, host: strings.ToLower(u.Host), prefix: u.Path}
+		if mode == ScopeDomain {
+			e.domain = registrableDomain(u.Hostname())
+		}
+		sc.entries = append(sc.entries, e)
+	}
+	for _, a := range allowed {
+		host, prefix, _ := strings.Cut(strings.TrimSpace(a), "/")
+		if host == "" {
+			return nil, fmt.Errorf("scope: invalid allowed host %q", a)
+		}
+		if _, err := path.Match(host, ""); err != nil {
+			return nil, fmt.Errorf("scope: invalid allowed host %q: %w", a, err)
+		}
+		sc.entries = append(sc.entries, scopeEntry{
+			name:   a,
+			host:   strings.ToLower(host),
+			glob:   strings.ContainsAny(host, "*?["),
+			prefix: "/" + prefix,
+		})
+	}
+	return sc, nil
+}
+
+// seedFor returns the seed (or allowed-host entry) whose scope contains u,
+// preferring the most specific (longest path prefix) one, or "" when u is
+// out of scope.
+func (s *scope) seedFor(u string) string {
+	t, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	best, bestLen := "", -1
+	for _, e := range s.entries {
+		if len(e.prefix) > bestLen && e.matches(t) {
+			best, bestLen = e.name, len(e.prefix)
+		}
+	}
+	return best
+}
+
+// contains reports whether u is within the scope of any seed.
+func (s *scope) contains(u string) bool {
+	return s.seedFor(u) != ""
+}
+
+// origins returns the distinct scheme://host origins of the seeds, in seed
+// order.
+func (s *scope) origins() []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, seed := range s.seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			continue
+		}
+		o := u.Scheme + "://" + u.Host
+		if !seen[o] {
+			seen[o] = true
+			out = append(out, o)
+		}
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScopeSeedFor(t *testing.T) {
+	sc, err := newScope([]string{
+		"https://example.com/guide",
+		"https://example.com/api",
+		"https://example.com/api/v2",
+		"https://docs.example.org/",
+	}, ScopeHost, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/guide/intro", "https://example.com/guide"},
+		{"https://example.com/api", "https://example.com/api"},
+		{"https://example.com/api/v1/users", "https://example.com/api"},
+		{"https://example.com/api/v2/users", "https://example.com/api/v2"},
+		{"https://docs.example.org/anything", "https://docs.example.org/"},
+		{"https://example.com/blog", ""},
+		{"https://example.com/guidebook", ""},
+	}
+	for _, tc := range tests {
+		if got := sc.seedFor(tc.url); got != tc.want {
+			t.Errorf("seedFor(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+
+	want := []string{"https://example.com", "https://docs.example.org"}
+	if got := sc.origins(); !slices.Equal(got, want) {
+		t.Errorf("origins() = %v, want %v", got, want)
+	}
+}
+
+func TestScopeModes(t *testing.T) {
+	seeds := []string{"https://docs.example.com/guide"}
+	tests := []struct {
+		mode    ScopeMode
+		allowed []string
+		url     string
+		want    string
+	}{
+		{ScopeHost, nil, "https://docs.example.com/guide/a", "https://docs.example.com/guide"},
+		{ScopeHost, nil, "https://www.example.com/guide/a", ""},
+		{ScopeDomain, nil, "https://www.example.com/guide/a", "https://docs.example.com/guide"},
+		{ScopeDomain, nil, "https://example.com/guide", "https://docs.example.com/guide"},
+		{ScopeDomain, nil, "https://example.com/blog", ""},
+		{ScopeDomain, nil, "https://example.org/guide", ""},
+		{ScopeHosts, []string{"api.example.com/v2"}, "https://api.example.com/v2/users", "api.example.com/v2"},
+		{ScopeHosts, []string{"api.example.com/v2"}, "https://api.example.com/v1/users", ""},
+		{ScopeHosts, []string{"*.cdn.example.com"}, "https://eu.cdn.example.com/x", "*.cdn.example.com"},
+		{ScopeHosts, []string{"*.cdn.example.com"}, "https://docs.example.com/guide/a", "https://docs.example.com/guide"},
+	}
+	for _, tc := range tests {
+		sc, err := newScope(seeds, tc.mode, tc.allowed)
+		if err != nil {
+			t.Fatalf("newScope(%s, %v): %v", tc.mode, tc.allowed, err)
+		}
+		if got := sc.seedFor(tc.url); got != tc.want {
+			t.Errorf("%s %v: seedFor(%q) = %q, want %q", tc.mode, tc.allowed, tc.url, got, tc.want)
+		}
+	}
+}
+
+func TestScopeInvalid(t *testing.T) {
+	seeds := []string{"https://example.com/"}
+	if _, err := newScope(seeds, "subdomain", nil); err == nil {
+		t.Error("unknown mode accepted")
+	}
+	if _, err := newScope(seeds, ScopeHost, []string{"api.example.com"}); err == nil {
+		t.Error("allowed hosts accepted outside hosts mode")
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := map[string]string{
+		"docs.example.com":  "example.com",
+		"WWW.Example.co.uk": "example.co.uk",
+		"localhost":         "localhost",
+		"127.0.0.1":         "127.0.0.1",
+	}
+	for host, want := range tests {
+		if got := registrableDomain(host); got != want {
+			t.Errorf("registrableDomain(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestRunWWWRedirectScope(t *testing.T) {
+	// One server plays both www.example.com, which redirects everything to
+	// the apex, and example.com.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "www.example.com" {
+			http.Redirect(w, r, "http://example.com"+r.URL.Path, http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprintf(w, "<html>%s on %s</html>", r.URL.Path, r.Host)
+	}))
+	t.Cleanup(srv.Close)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+
+	const seed, apex = "http://www.example.com/docs", "http://example.com/docs"
+	tests := []struct {
+		mode ScopeMode
+		skip string
+	}{
+		{ScopeHost, "redirected out of scope to " + apex},
+		{ScopeDomain, ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			out := t.TempDir()
+			res, err := Run(context.Background(), CrawlConfig{
+				StartURL:     seed,
+				OutputDir:    out,
+				ScopeMode:    tt.mode,
+				IgnoreRobots: true,
+				Fetcher:      mustFetcher(t, FetcherConfig{Transport: transport}),
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			page := res.Pages[seed]
+			if page == nil || page.RedirectTo != apex {
+				t.Fatalf("page = %+v, want RedirectTo %s", page, apex)
+			}
+			if got := res.Skipped[seed]; got != tt.skip {
+				t.Errorf("skip reason = %q, want %q", got, tt.skip)
+			}
+			if tt.skip != "" {
+				if len(res.Saved) != 0 {
+					t.Errorf("Saved = %v, want none", res.Saved)
+				}
+				return
+			}
+			want := filepath.Join(out, URLToFilename(apex))
+			if page.Path != want || !slices.Equal(res.Saved, []string{want}) {
+				t.Errorf("Path = %q, Saved = %v, want %s", page.Path, res.Saved, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSitemapDepth bounds how deeply sitemap index files may nest.
+	maxSitemapDepth = 5
+	// maxSitemapSize is the uncompressed size limit from the sitemaps protocol.
+	maxSitemapSize = 50 * 1024 * 1024
+)
+
+// SitemapEntry is a single <url> entry from a sitemap.
+type SitemapEntry struct {
+	// Loc is the page URL.
+	Loc string
+	// Priority is the <priority> value (0.0–1.0); 0.5 when absent.
+	Priority float64
+}
+
+// sitemapDoc covers both <urlset> and <sitemapindex> documents.
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapURL `xml:"url"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+type sitemapURL struct {
+	Loc      string `xml:"loc"`
+	Priority string `xml:"priority"`
+}
+
+// parseSitemap decodes a sitemap or sitemap index from r, transparently
+// decompressing gzip data. It returns the page entries and the URLs of any
+// nested sitemaps.
+func parseSitemap(r io.Reader) (entries []SitemapEntry, children []string, err error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gunzip: %w", err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+
+	var doc sitemapDoc
+	if err := xml.NewDecoder(io.LimitReader(src, maxSitemapSize)).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("parse xml: %w", err)
+	}
+
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" {
+				continue
+			}
+			prio := 0.5
+			if p, err := strconv.ParseFloat(strings.TrimSpace(u.Priority), 64); err == nil && p >= 0 && p <= 1 {
+				prio = p
+			}
+			entries = append(entries, SitemapEntry{Loc: loc, Priority: prio})
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				children = append(children, loc)
+			}
+		}
+	default:
+		return nil, nil, fmt.Errorf("unexpected root element <%s>", doc.XMLName.Local)
+	}
+	return entries, children, nil
+}
+
+// discoverSitemaps returns the in-scope page entries listed in the sitemaps
+// for cfg. Explicit cfg.SitemapURLs are used when given; otherwise each seed
+// host's robots.txt Sitemap: lines are taken from robots, falling back to
+// /sitemap.xml.
+// Nested sitemap indexes are expanded up to maxSitemapDepth levels.
+func discoverSitemaps(ctx context.Context, f Fetcher, robots *robotsCache, cfg CrawlConfig, sc *scope) []SitemapEntry {
+	roots := cfg.SitemapURLs
+	if len(roots) == 0 {
+		for _, origin := range sc.origins() {
+			// Only web origins have a robots.txt; a file:// origin would
+			// probe the root of the filesystem.
+			if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+				slog.Debug("sitemap: no discovery for non-HTTP seed", "origin", origin)
+				continue
+			}
+			roots = append(roots, robots.Sitemaps(ctx, origin)...)
+		}
+	}
+
+	seen := make(map[string]bool)
+	seenPage := make(map[string]bool)
+	var out []SitemapEntry
+
+	var walk func(sitemapURL string, depth int)
+	walk = func(sitemapURL string, depth int) {
+		if seen[sitemapURL] || ctx.Err() != nil {
+			return
+		}
+		seen[sitemapURL] = true
+		if depth > maxSitemapDepth {
+			slog.Warn("sitemap: nesting too deep, skipping", "url", sitemapURL)
+			return
+		}
+
+		entries, children, err := fetchSitemap(ctx, f, sitemapURL)
+		if err != nil {
+			slog.Warn("sitemap: load failed", "url", sitemapURL, "err", err)
+			return
+		}
+		slog.Debug("sitemap: loaded", "url", sitemapURL, "urls", len(entries), "sitemaps", len(children))
+
+		for _, e := range entries {
+			n := Normalize(e.Loc)
+			if n == "" || !crawlableURL(n) || !sc.contains(n) || seenPage[n] {
+				continue
+			}
+			seenPage[n] = true
+			e.Loc = n
+			out = append(out, e)
+		}
+		for _, c := range children {
+			walk(c, depth+1)
+		}
+	}
+
+	for _, r := range roots {
+		walk(r, 0)
+	}
+	slog.Info("sitemap: discovered", "sitemaps", len(seen), "urls", len(out))
+	return out
+}
+
+// fetchSitemap fetches and parses one sitemap, streaming large bodies from
+// the fetcher's spool file.
+func fetchSitemap(ctx context.Context, f Fetcher, sitemapURL string) ([]SitemapEntry, []string, error) {
+	resp, err := f.Fetch(ctx, sitemapURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Close()
+	body, err := resp.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read body: %w", err)
+	}
+	defer body.Close()
+	return parseSitemap(body)
+}
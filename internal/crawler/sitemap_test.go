@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseSitemapURLSet(t *testing.T) {
+	const xmlData = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/docs/a</loc><priority>0.8</priority></url>
+  <url><loc> https://example.com/docs/b </loc></url>
+  <url><loc></loc></url>
+</urlset>`
+
+	entries, children, err := parseSitemap(strings.NewReader(xmlData))
+	if err != nil {
+		t.Fatalf("parseSitemap: %v", err)
+	}
+	if len(children) != 0 {
+		t.Errorf("children = %v, want none", children)
+	}
+	want := []SitemapEntry{
+		{Loc: "https://example.com/docs/a", Priority: 0.8},
+		{Loc: "https://example.com/docs/b", Priority: 0.5},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %v, want %v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entries[%d] = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestParseSitemapIndexGzip(t *testing.T) {
+	const xmlData = `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-docs.xml.gz</loc></sitemap>
+  <sitemap><loc>https://example.com/sitemap-blog.xml</loc></sitemap>
+</sitemapindex>`
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(xmlData)); err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+
+	entries, children, err := parseSitemap(&buf)
+	if err != nil {
+		t.Fatalf("parseSitemap: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries = %v, want none", entries)
+	}
+	if len(children) != 2 || children[0] != "https://example.com/sitemap-docs.xml.gz" {
+		t.Errorf("children = %v", children)
+	}
+}
+
+func TestParseSitemapRejectsOtherXML(t *testing.T) {
+	if _, _, err := parseSitemap(strings.NewReader(`<rss><channel/></rss>`)); err == nil {
+		t.Error("expected error for non-sitemap XML")
+	}
+}
+
+func TestRunSitemapReusesRobots(t *testing.T) {
+	var robotsHits atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			robotsHits.Add(1)
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /private\nSitemap: %s/maps/docs.xml\n", srv.URL)
+		case "/maps/docs.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/docs/a</loc></url></urlset>`, srv.URL)
+		default:
+			fmt.Fprintf(w, "<html>%s</html>", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	res, err := Run(context.Background(), CrawlConfig{
+		StartURL:    srv.URL + "/docs/",
+		OutputDir:   t.TempDir(),
+		SitemapOnly: true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if p := res.Pages[srv.URL+"/docs/a"]; p == nil || p.Path == "" {
+		t.Errorf("sitemap page not saved: %+v", p)
+	}
+	if n := robotsHits.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want 1", n)
+	}
+}
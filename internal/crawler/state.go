@@ -0,0 +1,136 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golm-connector/internal/manifest"
+)
+
+const (
+	// StateFileName is the resumable-crawl state file written into OutputDir.
+	StateFileName = ".crawl-state.json"
+	// stateSaveInterval is how often the state file is refreshed during a crawl.
+	stateSaveInterval = 30 * time.Second
+	stateVersion      = 2
+)
+
+// queueItem is a URL waiting to be fetched.
+type queueItem struct {
+	URL      string  `json:"url"`
+	Depth    int     `json:"depth"`
+	Referrer string  `json:"referrer,omitempty"`
+	Priority float64 `json:"priority,omitempty"`
+	// seq is the discovery order within a run (see frontier).
+	seq int
+}
+
+// crawlState is the on-disk snapshot of an in-progress crawl: the frontier,
+// the visited set and every per-URL outcome recorded so far.
+type crawlState struct {
+	Version   int               `json:"version"`
+	Seeds     []string          `json:"seeds"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Queue     []queueItem       `json:"queue"`
+	Visited   []string          `json:"visited"`
+	Saved     []string          `json:"saved"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	Skipped   map[string]string `json:"skipped,omitempty"`
+	Pages     []*PageInfo       `json:"pages"`
+	// Previous is the manifest an incremental crawl compares against.
+	Previous []manifest.Entry `json:"previous,omitempty"`
+}
+
+func statePath(outputDir string) string {
+	return filepath.Join(outputDir, StateFileName)
+}
+
+// newCrawlState snapshots the dispatcher's bookkeeping.
+func newCrawlState(seeds []string, queue []queueItem, visited map[string]bool, res *CrawlResult) *crawlState {
+	st := &crawlState{
+		Version:   stateVersion,
+		Seeds:     seeds,
+		UpdatedAt: time.Now().UTC(),
+		Queue:     queue,
+		Visited:   make([]string, 0, len(visited)),
+		Saved:     res.Saved,
+		Errors:    res.Errors,
+		Skipped:   res.Skipped,
+		Pages:     make([]*PageInfo, 0, len(res.Pages)),
+	}
+	for u := range visited {
+		st.Visited = append(st.Visited, u)
+	}
+	sort.Strings(st.Visited)
+	for _, p := range res.Pages {
+		st.Pages = append(st.Pages, p)
+	}
+	sort.Slice(st.Pages, func(i, j int) bool { return st.Pages[i].URL < st.Pages[j].URL })
+	return st
+}
+
+// saveState atomically writes st to the state file in outputDir.
+func saveState(outputDir string, st *crawlState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("state: encode: %w", err)
+	}
+	path := statePath(outputDir)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("state: write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("state: rename %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadState reads the state file from outputDir.
+func loadState(outputDir string) (*crawlState, error) {
+	path := statePath(outputDir)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("state: read %s: %w", path, err)
+	}
+	var st crawlState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("state: unmarshal: %w", err)
+	}
+	if st.Version != stateVersion {
+		return nil, fmt.Errorf("state: unsupported version %d", st.Version)
+	}
+	return &st, nil
+}
+
+// restore copies the recorded outcomes into res and returns the frontier and
+// visited set to continue from.
+func (st *crawlState) restore(res *CrawlResult) ([]queueItem, map[string]bool) {
+	res.Saved = append(res.Saved, st.Saved...)
+	for u, e := range st.Errors {
+		res.Errors[u] = e
+	}
+	for u, r := range st.Skipped {
+		res.Skipped[u] = r
+	}
+	for _, p := range st.Pages {
+		res.Pages[p.URL] = p
+	}
+	visited := make(map[string]bool, len(st.Visited))
+	for _, u := range st.Visited {
+		visited[u] = true
+	}
+	return st.Queue, visited
+}
+
+// removeState deletes the state file once a crawl has finished.
+func removeState(outputDir string) error {
+	if err := os.Remove(statePath(outputDir)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("state: remove: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	res := &CrawlResult{
+		Saved:   []string{"out/example.com/docs.html"},
+		Errors:  map[string]string{"https://example.com/docs/bad": "http 500"},
+		Skipped: map[string]string{"https://example.com/docs/tags": "excluded by rule"},
+		Pages: map[string]*PageInfo{
+			"https://example.com/docs":     {URL: "https://example.com/docs", Path: "out/example.com/docs.html"},
+			"https://example.com/docs/bad": {URL: "https://example.com/docs/bad", Depth: 1},
+		},
+	}
+	visited := map[string]bool{
+		"https://example.com/docs":      true,
+		"https://example.com/docs/bad":  true,
+		"https://example.com/docs/tags": true,
+		"https://example.com/docs/next": true,
+	}
+	queue := []queueItem{{URL: "https://example.com/docs/next", Depth: 1}}
+
+	if err := saveState(dir, newCrawlState([]string{"https://example.com/docs"}, queue, visited, res)); err != nil {
+		t.Fatalf("saveState: %v", err)
+	}
+
+	st, err := loadState(dir)
+	if err != nil {
+		t.Fatalf("loadState: %v", err)
+	}
+	if len(st.Seeds) != 1 || st.Seeds[0] != "https://example.com/docs" {
+		t.Errorf("Seeds = %q", st.Seeds)
+	}
+
+	restored := &CrawlResult{
+		Errors:  make(map[string]string),
+		Skipped: make(map[string]string),
+		Pages:   make(map[string]*PageInfo),
+	}
+	gotQueue, gotVisited := st.restore(restored)
+
+	if len(gotQueue) != 1 || gotQueue[0] != queue[0] {
+		t.Errorf("queue = %v, want %v", gotQueue, queue)
+	}
+	if len(gotVisited) != len(visited) {
+		t.Errorf("visited has %d entries, want %d", len(gotVisited), len(visited))
+	}
+	if len(restored.Saved) != 1 || restored.Errors["https://example.com/docs/bad"] != "http 500" {
+		t.Errorf("restored result = %+v", restored)
+	}
+	if p := restored.Pages["https://example.com/docs/bad"]; p == nil || p.Depth != 1 {
+		t.Errorf("restored page = %+v", p)
+	}
+
+	if err := removeState(dir); err != nil {
+		t.Fatalf("removeState: %v", err)
+	}
+	if _, err := os.Stat(statePath(dir)); !os.IsNotExist(err) {
+		t.Errorf("state file still exists: %v", err)
+	}
+}
+
+func TestRunResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	interrupt := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		stop := interrupt && r.URL.Path == "/docs/a"
+		mu.Unlock()
+		if stop {
+			// Interrupt the first crawl once the seed page has been saved.
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		if r.URL.Path == "/docs" {
+			fmt.Fprint(w, `<html><a href="/docs/a">a</a><a href="/docs/b">b</a><a href="/docs/c">c</a></html>`)
+			return
+		}
+		fmt.Fprintf(w, "<html>%s</html>", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	cfg := CrawlConfig{StartURL: srv.URL + "/docs", OutputDir: t.TempDir(), IgnoreRobots: true, MaxConcurrency: 1}
+	first, err := Run(ctx, cfg)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("first Run: err = %v, want interruption", err)
+	}
+	if len(first.Saved) != 1 {
+		t.Fatalf("first run saved %v, want only the seed", first.Saved)
+	}
+	if _, err := os.Stat(statePath(cfg.OutputDir)); err != nil {
+		t.Fatalf("state file not written: %v", err)
+	}
+
+	mu.Lock()
+	interrupt = false
+	mu.Unlock()
+	cfg.Resume = true
+	res, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requested["/docs"] != 1 {
+		t.Errorf("seed fetched %d times, want once", requested["/docs"])
+	}
+	for _, path := range []string{"/docs/a", "/docs/b", "/docs/c"} {
+		if p := res.Pages[srv.URL+path]; p == nil || p.Path == "" {
+			t.Errorf("%s not saved after resuming: %+v", path, p)
+		}
+	}
+	saved := slices.Clone(res.Saved)
+	slices.Sort(saved)
+	if len(slices.Compact(saved)) != 4 || len(res.Saved) != 4 {
+		t.Errorf("Saved = %v, want 4 distinct files", res.Saved)
+	}
+	if _, err := os.Stat(statePath(cfg.OutputDir)); !os.IsNotExist(err) {
+		t.Errorf("state file left after the crawl finished (err %v)", err)
+	}
+}
@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// newTransport builds the HTTP transport for cfg: the default transport with
+// an explicit proxy (or the environment's HTTP_PROXY/HTTPS_PROXY/NO_PROXY),
+// extra root CAs, an optional client certificate and, when asked, no
+// certificate verification.
+func newTransport(cfg FetcherConfig) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			// The URL may carry proxy credentials, so it is not echoed.
+			return nil, fmt.Errorf("invalid proxy URL")
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if len(cfg.CACertFiles) == 0 && cfg.ClientCert == "" && cfg.ClientKey == "" && !cfg.InsecureSkipVerify {
+		return t, nil
+	}
+
+	tc := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(cfg.CACertFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range cfg.CACertFiles {
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read CA bundle: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("CA bundle %s: no PEM certificates found", path)
+			}
+		}
+		tc.RootCAs = pool
+	}
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.InsecureSkipVerify {
+		slog.Warn("TLS certificate verification is disabled")
+		tc.InsecureSkipVerify = true
+	}
+	t.TLSClientConfig = tc
+	return t, nil
+}
@@ -0,0 +1,116 @@
+package crawler
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustFetcher(t *testing.T, cfg FetcherConfig) *HTTPFetcher {
+	t.Helper()
+	f, err := NewHTTPFetcher(cfg)
+	if err != nil {
+		t.Fatalf("NewHTTPFetcher: %v", err)
+	}
+	return f
+}
+
+func TestFetchCustomCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	if _, err := mustFetcher(t, FetcherConfig{}).Fetch(ctx, srv.URL); err == nil {
+		t.Error("fetch without CA bundle succeeded, want certificate error")
+	}
+
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(ca, block, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mustFetcher(t, FetcherConfig{CACertFiles: []string{ca}}).Fetch(ctx, srv.URL); err != nil {
+		t.Errorf("fetch with CA bundle: %v", err)
+	}
+	if _, err := mustFetcher(t, FetcherConfig{InsecureSkipVerify: true}).Fetch(ctx, srv.URL); err != nil {
+		t.Errorf("fetch with insecure-skip-verify: %v", err)
+	}
+}
+
+func TestFetchProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprint(w, "via proxy")
+	}))
+	defer proxy.Close()
+
+	f := mustFetcher(t, FetcherConfig{Proxy: proxy.URL})
+	resp, err := f.Fetch(context.Background(), "http://docs.example.invalid/page")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if proxied != "http://docs.example.invalid/page" || string(resp.Body) != "via proxy" {
+		t.Errorf("proxy saw %q, body %q", proxied, resp.Body)
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	f := mustFetcher(t, FetcherConfig{Timeout: 20 * time.Millisecond})
+	_, err := f.Fetch(context.Background(), srv.URL)
+	if fe, ok := err.(*FetchError); !ok || !fe.Transient {
+		t.Errorf("Fetch err = %v, want transient timeout", err)
+	}
+}
+
+func TestNewHTTPFetcherInvalidTLSConfig(t *testing.T) {
+	for _, cfg := range []FetcherConfig{
+		{Proxy: "://bad"},
+		{CACertFiles: []string{filepath.Join(t.TempDir(), "missing.pem")}},
+		{ClientCert: "cert.pem"},
+	} {
+		if _, err := NewHTTPFetcher(cfg); err == nil {
+			t.Errorf("NewHTTPFetcher(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return fn(r) }
+
+func TestFetchCustomTransport(t *testing.T) {
+	f := mustFetcher(t, FetcherConfig{
+		Proxy: "::not a proxy::", // ignored with a custom transport
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"text/html"}},
+				Body:       io.NopCloser(strings.NewReader("mirrored " + r.URL.Path)),
+				Request:    r,
+			}, nil
+		}),
+	})
+	resp, err := f.Fetch(context.Background(), "https://example.com/docs")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if string(resp.Body) != "mirrored /docs" {
+		t.Errorf("Body = %q, want %q", resp.Body, "mirrored /docs")
+	}
+}
@@ -1,8 +1,12 @@
 package crawler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/url"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/html"
@@ -14,10 +18,11 @@ import (
 //   - fragment is removed
 //   - trailing slash is normalised (root path keeps "/", others strip it)
 //
-// Returns "" on parse error.
+// Returns "" on parse error and for relative or host-less URLs other than
+// file:///path.
 func Normalize(rawURL string) string {
 	u, err := url.Parse(strings.TrimSpace(rawURL))
-	if err != nil || u.Scheme == "" || u.Host == "" {
+	if err != nil || u.Scheme == "" || (u.Host == "" && !strings.EqualFold(u.Scheme, "file")) {
 		return ""
 	}
 	u.Scheme = strings.ToLower(u.Scheme)
@@ -32,6 +37,86 @@ func Normalize(rawURL string) string {
 	return u.String()
 }
 
+// DefaultTrackingParams are query parameters that identify campaigns or
+// sessions rather than content. A trailing "*" matches any suffix.
+var DefaultTrackingParams = []string{
+	"utm_*", "gclid", "dclid", "fbclid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "ref_src",
+	"phpsessid", "jsessionid",
+}
+
+// NormalizeOptions controls how NormalizeWith treats query strings.
+type NormalizeOptions struct {
+	// DropParams lists query parameter names to remove (case-insensitive);
+	// a trailing "*" matches any suffix, e.g. "utm_*".
+	DropParams []string
+	// SortQuery sorts the remaining parameters by name. Repeated names keep
+	// their relative order.
+	SortQuery bool
+	// IgnoreQuery removes the whole query string.
+	IgnoreQuery bool
+}
+
+// NormalizeWith is like Normalize but additionally rewrites the query string
+// according to opts. Parameters are kept in their original encoding.
+func NormalizeWith(rawURL string, opts NormalizeOptions) string {
+	n := Normalize(rawURL)
+	if n == "" || (len(opts.DropParams) == 0 && !opts.SortQuery && !opts.IgnoreQuery) {
+		return n
+	}
+	u, err := url.Parse(n)
+	if err != nil {
+		return ""
+	}
+	u.ForceQuery = false
+	if opts.IgnoreQuery || u.RawQuery == "" {
+		u.RawQuery = ""
+		return u.String()
+	}
+
+	type param struct{ name, raw string }
+	var params []param
+	for _, raw := range strings.Split(u.RawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(raw, "=")
+		if dec, err := url.QueryUnescape(name); err == nil {
+			name = dec
+		}
+		if matchParam(opts.DropParams, name) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+	if opts.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool { return params[i].name < params[j].name })
+	}
+
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+	u.RawQuery = strings.Join(raws, "&")
+	return u.String()
+}
+
+// matchParam reports whether name matches one of the patterns.
+func matchParam(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if p == name {
+			return true
+		}
+	}
+	return false
+}
+
 // InScope reports whether target is within the scope defined by base.
 // Scope is: same host AND target path has base path as a prefix.
 func InScope(base, target string) bool {
@@ -46,17 +131,38 @@ func InScope(base, target string) bool {
 	if !strings.EqualFold(b.Host, t.Host) {
 		return false
 	}
-	scopePrefix := b.Path
+	return pathInScope(b.Path, t.Path)
+}
+
+// pathInScope reports whether target equals prefix or lies below it.
+func pathInScope(prefix, target string) bool {
+	scopePrefix := prefix
 	if !strings.HasSuffix(scopePrefix, "/") {
 		scopePrefix = scopePrefix + "/"
 	}
-	return strings.HasPrefix(t.Path, scopePrefix) || t.Path == strings.TrimRight(scopePrefix, "/")
+	return strings.HasPrefix(target, scopePrefix) || target == strings.TrimRight(scopePrefix, "/")
+}
+
+// LinkOptions controls which links ExtractLinksWith returns.
+type LinkOptions struct {
+	// FollowLinkRels also returns navigational <link> elements: rel "next"
+	// and "prev", and rel "alternate" with an hreflang. Other <link>
+	// elements (stylesheets, icons, preload hints, feeds) are never returned.
+	FollowLinkRels bool
+	// IgnoreNofollow also returns <a rel="nofollow"> links.
+	IgnoreNofollow bool
 }
 
-// ExtractLinks parses an HTML document and returns all href/src link URLs
-// resolved against baseURL, de-duplicated and in document order.
+// ExtractLinks returns the <a href> links of an HTML document that are not
+// rel="nofollow", resolved against the document's <base href> (or baseURL),
+// normalized, de-duplicated and in document order.
 func ExtractLinks(baseURL string, doc *html.Node) []string {
-	base, err := url.Parse(baseURL)
+	return ExtractLinksWith(baseURL, doc, LinkOptions{})
+}
+
+// ExtractLinksWith is like ExtractLinks but selects links according to opts.
+func ExtractLinksWith(baseURL string, doc *html.Node, opts LinkOptions) []string {
+	base, err := documentBase(baseURL, doc)
 	if err != nil {
 		return nil
 	}
@@ -67,15 +173,19 @@ func ExtractLinks(baseURL string, doc *html.Node) []string {
 	var walk func(*html.Node)
 	walk = func(n *html.Node) {
 		if n.Type == html.ElementNode {
-			attr := ""
+			href := ""
 			switch n.Data {
 			case "a":
-				attr = getAttr(n, "href")
+				if opts.IgnoreNofollow || !hasToken(getAttr(n, "rel"), "nofollow") {
+					href = getAttr(n, "href")
+				}
 			case "link":
-				attr = getAttr(n, "href")
+				if opts.FollowLinkRels && navigationalLink(n) {
+					href = getAttr(n, "href")
+				}
 			}
-			if attr != "" {
-				ref, err := url.Parse(attr)
+			if href != "" {
+				ref, err := url.Parse(strings.TrimSpace(href))
 				if err == nil {
 					abs := base.ResolveReference(ref).String()
 					abs = Normalize(abs)
@@ -96,18 +206,238 @@ func ExtractLinks(baseURL string, doc *html.Node) []string {
 	return links
 }
 
+// navigationalLink reports whether a <link> element points to another page
+// of the same document series or to a translation of the page.
+func navigationalLink(n *html.Node) bool {
+	rel := getAttr(n, "rel")
+	if hasToken(rel, "next") || hasToken(rel, "prev") || hasToken(rel, "previous") {
+		return true
+	}
+	return hasToken(rel, "alternate") && getAttr(n, "hreflang") != ""
+}
+
+// documentBase returns the URL relative links in doc resolve against: the
+// first <base href>, itself resolved against baseURL, or baseURL.
+func documentBase(baseURL string, doc *html.Node) (*url.URL, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var href string
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "base" {
+			if h, ok := attr(n, "href"); ok {
+				href = strings.TrimSpace(h)
+				return true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(doc)
+	if ref, err := url.Parse(href); err == nil && href != "" {
+		return base.ResolveReference(ref), nil
+	}
+	return base, nil
+}
+
+// RobotsDirectives are the crawler directives of a page's
+// <meta name="robots">.
+type RobotsDirectives struct {
+	// NoIndex asks crawlers not to keep the page.
+	NoIndex bool
+	// NoFollow asks crawlers not to follow the page's links.
+	NoFollow bool
+}
+
+// MetaRobots returns the directives of the document's <meta name="robots">
+// elements. "none" counts as both noindex and nofollow.
+func MetaRobots(doc *html.Node) RobotsDirectives {
+	var d RobotsDirectives
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(getAttr(n, "name"), "robots") {
+			for _, tok := range strings.FieldsFunc(getAttr(n, "content"), func(r rune) bool { return r == ',' || r == ' ' }) {
+				switch strings.ToLower(tok) {
+				case "noindex":
+					d.NoIndex = true
+				case "nofollow":
+					d.NoFollow = true
+				case "none":
+					d.NoIndex, d.NoFollow = true, true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return d
+}
+
+// CanonicalURL returns the normalized absolute URL of the document's
+// <link rel="canonical">, resolved against the document's base URL, or ""
+// if there is none.
+func CanonicalURL(baseURL string, doc *html.Node) string {
+	base, err := documentBase(baseURL, doc)
+	if err != nil {
+		return ""
+	}
+
+	var found string
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "link" && hasToken(getAttr(n, "rel"), "canonical") {
+			if ref, err := url.Parse(strings.TrimSpace(getAttr(n, "href"))); err == nil && ref.String() != "" {
+				found = Normalize(base.ResolveReference(ref).String())
+				return true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(doc)
+	return found
+}
+
+// metaRefreshMaxDelay is the longest <meta http-equiv="refresh"> delay
+// treated as a redirect; longer ones are usually periodic reloads.
+const metaRefreshMaxDelay = 10
+
+// MetaRefreshURL returns the normalized absolute target of the document's
+// <meta http-equiv="refresh" content="N; url=...">, resolved against the
+// document's base URL, or "" if there is none or its delay exceeds
+// metaRefreshMaxDelay seconds.
+func MetaRefreshURL(baseURL string, doc *html.Node) string {
+	base, err := documentBase(baseURL, doc)
+	if err != nil {
+		return ""
+	}
+
+	var found string
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(getAttr(n, "http-equiv"), "refresh") {
+			if target, ok := parseRefresh(getAttr(n, "content")); ok {
+				if ref, err := url.Parse(target); err == nil {
+					found = Normalize(base.ResolveReference(ref).String())
+				}
+			}
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(doc)
+	return found
+}
+
+// parseRefresh parses a refresh content value such as "0; url=/new/" or
+// "3;URL='page.html'" and returns the target URL when the delay is short
+// enough to count as a redirect.
+func parseRefresh(content string) (string, bool) {
+	delay, rest, ok := strings.Cut(strings.TrimSpace(content), ";")
+	if !ok {
+		delay, rest, ok = strings.Cut(strings.TrimSpace(content), ",")
+	}
+	if !ok {
+		return "", false
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSpace(delay), 64)
+	if err != nil || secs < 0 || secs > metaRefreshMaxDelay {
+		return "", false
+	}
+	rest = strings.TrimSpace(rest)
+	if len(rest) >= 4 && strings.EqualFold(rest[:3], "url") {
+		if after, ok := strings.CutPrefix(strings.TrimSpace(rest[3:]), "="); ok {
+			rest = strings.TrimSpace(after)
+		}
+	}
+	rest = strings.Trim(rest, `"'`)
+	return rest, rest != ""
+}
+
+// HTMLTitle returns the whitespace-collapsed text of the document's first
+// <title> element, or "".
+func HTMLTitle(doc *html.Node) string {
+	var title string
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "title" {
+			var sb strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					sb.WriteString(c.Data)
+				}
+			}
+			title = strings.Join(strings.Fields(sb.String()), " ")
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(doc)
+	return title
+}
+
+// hasToken reports whether the space-separated list s contains tok
+// (case-insensitively), as used by rel and content attributes.
+func hasToken(s, tok string) bool {
+	for _, f := range strings.Fields(s) {
+		if strings.EqualFold(f, tok) {
+			return true
+		}
+	}
+	return false
+}
+
 func getAttr(n *html.Node, key string) string {
+	v, _ := attr(n, key)
+	return v
+}
+
+// attr returns the value of n's key attribute and whether it is present.
+func attr(n *html.Node, key string) (string, bool) {
 	for _, a := range n.Attr {
 		if a.Key == key {
-			return a.Val
+			return a.Val, true
 		}
 	}
-	return ""
+	return "", false
 }
 
 // URLToFilename converts a URL to a safe relative file path ending in .html.
 // e.g. https://example.com/docs/intro → example.com/docs/intro.html
+//
+// A query string is encoded as a short hash so that different views of the
+// same path do not overwrite each other:
+// https://example.com/docs/list?page=2 → example.com/docs/list_q<hash>.html
 func URLToFilename(rawURL string) string {
+	return URLToFilenameExt(rawURL, ".html")
+}
+
+// URLToFilenameExt is like URLToFilename but uses ext as the file extension.
+// e.g. https://example.com/docs/manual.pdf, ".pdf" → example.com/docs/manual.pdf
+func URLToFilenameExt(rawURL, ext string) string {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return ""
@@ -118,8 +448,15 @@ func URLToFilename(rawURL string) string {
 	}
 	// Replace path separators that might cause issues; keep slashes for dirs.
 	p = strings.ReplaceAll(p, "..", "__")
-	if !strings.HasSuffix(p, ".html") {
-		p += ".html"
+	p = strings.TrimSuffix(p, ext)
+	if u.RawQuery != "" {
+		p += "_q" + queryHash(u.RawQuery)
 	}
-	return path.Join(u.Host, p)
+	return path.Join(u.Host, p+ext)
+}
+
+// queryHash returns a short, stable hash of a raw query string.
+func queryHash(rawQuery string) string {
+	h := sha256.Sum256([]byte(rawQuery))
+	return hex.EncodeToString(h[:4])
 }
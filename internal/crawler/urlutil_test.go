@@ -154,6 +154,71 @@ func TestExtractLinks(t *testing.T) {
 	}
 }
 
+func TestExtractLinksWith(t *testing.T) {
+	const rawHTML = `<html><head>
+<base href="/docs/v2/">
+<link rel="stylesheet" href="/style.css">
+<link rel="icon" href="/favicon.ico">
+<link rel="preload" href="/font.woff2">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="alternate" hreflang="ja" href="/ja/docs/v2/">
+<link rel="next" href="page2">
+</head><body>
+<a href="intro">Intro</a>
+<a href="/login" rel="nofollow">Log in</a>
+</body></html>`
+	doc, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		t.Fatalf("parse HTML: %v", err)
+	}
+	base := "https://example.com/docs/v2/index.html"
+
+	tests := []struct {
+		opts LinkOptions
+		want []string
+	}{
+		{LinkOptions{}, []string{"https://example.com/docs/v2/intro"}},
+		{LinkOptions{FollowLinkRels: true}, []string{
+			"https://example.com/ja/docs/v2",
+			"https://example.com/docs/v2/page2",
+			"https://example.com/docs/v2/intro",
+		}},
+		{LinkOptions{IgnoreNofollow: true}, []string{
+			"https://example.com/docs/v2/intro",
+			"https://example.com/login",
+		}},
+	}
+	for _, tc := range tests {
+		got := ExtractLinksWith(base, doc, tc.opts)
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("ExtractLinksWith(%+v) = %v, want %v", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestMetaRobots(t *testing.T) {
+	tests := []struct {
+		meta string
+		want RobotsDirectives
+	}{
+		{``, RobotsDirectives{}},
+		{`<meta name="robots" content="index, follow">`, RobotsDirectives{}},
+		{`<meta name="robots" content="noindex">`, RobotsDirectives{NoIndex: true}},
+		{`<meta name="ROBOTS" content="NOFOLLOW,NOINDEX">`, RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{`<meta name="robots" content="none">`, RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{`<meta name="description" content="noindex">`, RobotsDirectives{}},
+	}
+	for _, tc := range tests {
+		doc, err := html.Parse(strings.NewReader("<html><head>" + tc.meta + "</head></html>"))
+		if err != nil {
+			t.Fatalf("parse HTML: %v", err)
+		}
+		if got := MetaRobots(doc); got != tc.want {
+			t.Errorf("MetaRobots(%s) = %+v, want %+v", tc.meta, got, tc.want)
+		}
+	}
+}
+
 func TestURLToFilename(t *testing.T) {
 	tests := []struct {
 		url  string
@@ -170,3 +235,156 @@ func TestURLToFilename(t *testing.T) {
 		}
 	}
 }
+
+func TestURLToFilenameExt(t *testing.T) {
+	tests := []struct {
+		url  string
+		ext  string
+		want string
+	}{
+		{"https://example.com/docs/manual.pdf", ".pdf", "example.com/docs/manual.pdf"},
+		{"https://example.com/docs/LICENSE", ".txt", "example.com/docs/LICENSE.txt"},
+		{"https://example.com/", ".txt", "example.com/index.txt"},
+	}
+	for _, tc := range tests {
+		got := URLToFilenameExt(tc.url, tc.ext)
+		if got != tc.want {
+			t.Errorf("URLToFilenameExt(%q, %q) = %q, want %q", tc.url, tc.ext, got, tc.want)
+		}
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "relative canonical resolved",
+			html: `<html><head><link rel="canonical" href="/docs/intro/"></head></html>`,
+			want: "https://example.com/docs/intro",
+		},
+		{
+			name: "rel token list",
+			html: `<html><head><link rel="Canonical alternate" href="https://example.com/a#top"></head></html>`,
+			want: "https://example.com/a",
+		},
+		{
+			name: "no canonical",
+			html: `<html><head><link rel="stylesheet" href="/s.css"></head></html>`,
+			want: "",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tc.html))
+			if err != nil {
+				t.Fatalf("parse HTML: %v", err)
+			}
+			if got := CanonicalURL("https://example.com/docs/intro/index.html", doc); got != tc.want {
+				t.Errorf("CanonicalURL = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMetaRefreshURL(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "relative target",
+			html: `<html><head><meta http-equiv="refresh" content="0; url=../moved/"></head></html>`,
+			want: "https://example.com/docs/moved",
+		},
+		{
+			name: "quoted target without space",
+			html: `<html><head><meta http-equiv="Refresh" content="3;URL='https://example.com/new'"></head></html>`,
+			want: "https://example.com/new",
+		},
+		{
+			name: "periodic reload",
+			html: `<html><head><meta http-equiv="refresh" content="300; url=/docs/intro/"></head></html>`,
+			want: "",
+		},
+		{
+			name: "reload without target",
+			html: `<html><head><meta http-equiv="refresh" content="5"></head></html>`,
+			want: "",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tc.html))
+			if err != nil {
+				t.Fatalf("parse HTML: %v", err)
+			}
+			if got := MetaRefreshURL("https://example.com/docs/intro/index.html", doc); got != tc.want {
+				t.Errorf("MetaRefreshURL = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeWith(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  NormalizeOptions
+		want  string
+	}{
+		{
+			name:  "no options keeps query verbatim",
+			input: "https://example.com/docs?b=2&a=1",
+			want:  "https://example.com/docs?b=2&a=1",
+		},
+		{
+			name:  "tracking parameters dropped",
+			input: "https://example.com/docs?utm_source=x&page=2&UTM_Medium=y&gclid=abc",
+			opts:  NormalizeOptions{DropParams: DefaultTrackingParams},
+			want:  "https://example.com/docs?page=2",
+		},
+		{
+			name:  "query emptied by dropping",
+			input: "https://example.com/docs/?utm_source=x",
+			opts:  NormalizeOptions{DropParams: DefaultTrackingParams},
+			want:  "https://example.com/docs",
+		},
+		{
+			name:  "sorted by name, repeated names keep order",
+			input: "https://example.com/docs?b=2&a=9&a=1&c=%20",
+			opts:  NormalizeOptions{SortQuery: true},
+			want:  "https://example.com/docs?a=9&a=1&b=2&c=%20",
+		},
+		{
+			name:  "ignore query",
+			input: "https://example.com/docs?page=2#frag",
+			opts:  NormalizeOptions{IgnoreQuery: true},
+			want:  "https://example.com/docs",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NormalizeWith(tc.input, tc.opts); got != tc.want {
+				t.Errorf("NormalizeWith(%q) = %q, want %q", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestURLToFilenameQuery(t *testing.T) {
+	p1 := URLToFilename("https://example.com/docs/list?page=1")
+	p2 := URLToFilename("https://example.com/docs/list?page=2")
+	if p1 == p2 {
+		t.Fatalf("different queries map to the same file %q", p1)
+	}
+	if !strings.HasPrefix(p1, "example.com/docs/list_q") || !strings.HasSuffix(p1, ".html") {
+		t.Errorf("URLToFilename with query = %q", p1)
+	}
+	if again := URLToFilename("https://example.com/docs/list?page=1"); again != p1 {
+		t.Errorf("filename not stable: %q vs %q", again, p1)
+	}
+}
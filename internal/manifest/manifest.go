@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileName is the manifest file written at the root of an output tree.
+const FileName = "manifest.jsonl"
+
+// Entry maps one saved file to the page it came from.
+type Entry struct {
+	// URL is the normalized URL that was requested.
+	URL string `json:"url"`
+	// FinalURL is the URL after redirects.
+	FinalURL string `json:"final_url,omitempty"`
+	// Path is the saved file, relative to the manifest's directory, with
+	// forward slashes.
+	Path string `json:"path"`
+	// Status is the HTTP status code of the response.
+	Status int `json:"status,omitempty"`
+	// ContentType is the response media type.
+	ContentType string `json:"content_type,omitempty"`
+	// Size is the body size in bytes.
+	Size int64 `json:"size"`
+	// Hash is the hex SHA-256 of the body.
+	Hash string `json:"hash,omitempty"`
+	// Depth is the link depth from the seed.
+	Depth int `json:"depth"`
+	// Seed is the seed URL whose scope the page was found under.
+	Seed string `json:"seed,omitempty"`
+	// Referrer is the page the URL was discovered on ("" for seeds).
+	Referrer string `json:"referrer,omitempty"`
+	// FetchedAt is when the body was downloaded.
+	FetchedAt time.Time `json:"fetched_at,omitzero"`
+	// Title is the HTML <title>, if any.
+	Title string `json:"title,omitempty"`
+	// Charset is the page's original encoding; saved HTML is always UTF-8.
+	Charset string `json:"charset,omitempty"`
+}
+
+// Write writes entries to path as JSON Lines.
+func Write(path string, entries []Entry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("manifest: create %s: %w", path, err)
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return fmt.Errorf("manifest: encode: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("manifest: write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("manifest: close %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load reads a manifest written by Write.
+func Load(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("manifest: decode %s: %w", path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// LoadDir reads dir's manifest and indexes it by Path. It returns a nil map
+// and no error when dir has no manifest.
+func LoadDir(dir string) (map[string]Entry, error) {
+	entries, err := Load(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	byPath := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		byPath[e.Path] = e
+	}
+	return byPath, nil
+}
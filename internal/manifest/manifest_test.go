@@ -0,0 +1,56 @@
+package manifest
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	fetched := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{
+			URL:         "https://example.com/docs",
+			FinalURL:    "https://example.com/docs/",
+			Path:        "example.com/docs.html",
+			Status:      200,
+			ContentType: "text/html",
+			Size:        42,
+			Hash:        "abc",
+			FetchedAt:   fetched,
+			Title:       "Docs",
+		},
+		{
+			URL:      "https://example.com/docs/a",
+			Path:     "example.com/docs/a.html",
+			Depth:    1,
+			Referrer: "https://example.com/docs",
+		},
+	}
+	if err := Write(filepath.Join(dir, FileName), entries); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	byPath, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	if len(byPath) != 2 {
+		t.Fatalf("LoadDir returned %d entries, want 2", len(byPath))
+	}
+	got := byPath["example.com/docs.html"]
+	if got.URL != entries[0].URL || got.Title != "Docs" || !got.FetchedAt.Equal(fetched) {
+		t.Errorf("entry = %+v, want %+v", got, entries[0])
+	}
+	if got := byPath["example.com/docs/a.html"]; got.Referrer != "https://example.com/docs" || got.Depth != 1 {
+		t.Errorf("entry = %+v, want %+v", got, entries[1])
+	}
+}
+
+func TestLoadDirMissing(t *testing.T) {
+	byPath, err := LoadDir(t.TempDir())
+	if err != nil || byPath != nil {
+		t.Errorf("LoadDir(empty) = %v, %v; want nil, nil", byPath, err)
+	}
+}
@@ -13,10 +13,20 @@ const (
 
 // URLStatus records the outcome of crawling or converting a single URL/file.
 type URLStatus struct {
-	URL    string    `json:"url"`
-	Status Status    `json:"status"`
-	Error  string    `json:"error,omitempty"`
-	Time   time.Time `json:"time"`
+	URL         string    `json:"url"`
+	Status      Status    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Path        string    `json:"path,omitempty"`
+	Depth       int       `json:"depth,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+	ErrorClass  string    `json:"error_class,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Canonical   string    `json:"canonical,omitempty"`
+	RedirectTo  string    `json:"redirect_to,omitempty"`
+	Change      string    `json:"change,omitempty"`
+	Seed        string    `json:"seed,omitempty"`
+	Time        time.Time `json:"time"`
 }
 
 // StepResult holds the aggregate result of one pipeline step.
@@ -0,0 +1,105 @@
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header is the header of a record returned by Reader.Next.
+type Header struct {
+	// Type is the WARC-Type, e.g. TypeResponse.
+	Type string
+	// ID is the WARC-Record-ID.
+	ID string
+	// TargetURI is the WARC-Target-URI, without the angle brackets some
+	// WARC 1.0 writers put around it.
+	TargetURI string
+	// Date is the WARC-Date (zero if missing or malformed).
+	Date time.Time
+	// ContentType is the Content-Type of the block.
+	ContentType string
+	// Length is the size of the block in bytes.
+	Length int64
+	// Fields holds every header field, keyed by canonical MIME header name.
+	Fields textproto.MIMEHeader
+}
+
+// Reader reads the records of a WARC file, whether it is gzipped per
+// record, gzipped as a whole or not compressed.
+type Reader struct {
+	br    *bufio.Reader
+	block *io.LimitedReader
+}
+
+// NewReader returns a Reader for r, detecting gzip compression.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		// Concatenated members are read as one stream.
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("warc: %w", err)
+		}
+		br = bufio.NewReader(zr)
+	}
+	return &Reader{br: br}, nil
+}
+
+// Next returns the header and block of the next record, skipping whatever
+// was left unread of the previous block. The block is valid until the next
+// call. Next returns io.EOF after the last record.
+func (r *Reader) Next() (*Header, io.Reader, error) {
+	if r.block != nil {
+		if _, err := io.Copy(io.Discard, r.block); err != nil {
+			return nil, nil, fmt.Errorf("warc: skip block: %w", err)
+		}
+		if r.block.N > 0 {
+			return nil, nil, fmt.Errorf("warc: truncated record: %w", io.ErrUnexpectedEOF)
+		}
+		r.block = nil
+	}
+
+	// Records are separated by blank lines.
+	var version string
+	for version == "" {
+		line, err := r.br.ReadString('\n')
+		version = strings.TrimSpace(line)
+		if errors.Is(err, io.EOF) && version == "" {
+			return nil, nil, io.EOF
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("warc: read record: %w", err)
+		}
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return nil, nil, fmt.Errorf("warc: bad version line %q", version)
+	}
+
+	fields, err := textproto.NewReader(r.br).ReadMIMEHeader()
+	if err != nil {
+		return nil, nil, fmt.Errorf("warc: read header: %w", err)
+	}
+	length, err := strconv.ParseInt(fields.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return nil, nil, fmt.Errorf("warc: bad Content-Length %q", fields.Get("Content-Length"))
+	}
+	h := &Header{
+		Type:        fields.Get("WARC-Type"),
+		ID:          fields.Get("WARC-Record-ID"),
+		TargetURI:   strings.Trim(fields.Get("WARC-Target-URI"), "<>"),
+		ContentType: fields.Get("Content-Type"),
+		Length:      length,
+		Fields:      fields,
+	}
+	h.Date, _ = time.Parse(time.RFC3339Nano, fields.Get("WARC-Date"))
+
+	r.block = &io.LimitedReader{R: r.br, N: length}
+	return h, r.block, nil
+}
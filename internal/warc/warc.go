@@ -0,0 +1,279 @@
+// Package warc reads and writes WARC archives. The Writer produces WARC 1.1
+// files with one gzip member per record, rotated once they reach a size
+// limit; the Reader accepts files from other tools as well.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record types written by this package.
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeResource = "resource"
+	TypeMetadata = "metadata"
+)
+
+// Content types of record blocks.
+const (
+	ContentTypeRequest  = "application/http;msgtype=request"
+	ContentTypeResponse = "application/http;msgtype=response"
+	ContentTypeFields   = "application/warc-fields"
+)
+
+// DefaultMaxSize is the file size after which a Writer starts a new file
+// when Config.MaxSize is 0.
+const DefaultMaxSize = 1 << 30
+
+// Record is one WARC record. Its block is HTTPHeader followed by Payload;
+// Content-Length and the digests are computed by the Writer.
+type Record struct {
+	// ID is the WARC-Record-ID ("" = a new one from NewRecordID).
+	ID string
+	// Type is the WARC-Type, e.g. TypeResponse.
+	Type string
+	// TargetURI is the WARC-Target-URI ("" for warcinfo records).
+	TargetURI string
+	// Date is the WARC-Date: when the content was captured.
+	Date time.Time
+	// ContentType is the Content-Type of the block.
+	ContentType string
+	// Fields are extra WARC header fields such as WARC-Concurrent-To.
+	Fields map[string]string
+	// HTTPHeader is the HTTP start line and header block of request and
+	// response records, including the terminating empty line.
+	HTTPHeader []byte
+	// Payload is the rest of the block. It is read from the start twice:
+	// once to digest it and once to write it.
+	Payload io.ReadSeeker
+}
+
+// Config configures a Writer.
+type Config struct {
+	// Dir is the directory the archive files are created in.
+	Dir string
+	// Prefix starts every file name ("" = "crawl").
+	Prefix string
+	// MaxSize is the size in bytes after which a new file is started
+	// (0 = DefaultMaxSize). Records are never split across files.
+	MaxSize int64
+	// Software is recorded in each file's warcinfo record.
+	Software string
+}
+
+// Writer appends records to a series of .warc.gz files named
+// "<prefix>-<timestamp>-<n>.warc.gz". It is safe for concurrent use.
+type Writer struct {
+	cfg   Config
+	stamp string
+
+	mu    sync.Mutex
+	seq   int
+	f     *os.File
+	size  int64
+	files []string
+}
+
+// NewWriter creates cfg.Dir and returns a Writer for it. The first file is
+// created with the first record.
+func NewWriter(cfg Config) (*Writer, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "crawl"
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("warc: mkdir %s: %w", cfg.Dir, err)
+	}
+	return &Writer{cfg: cfg, stamp: time.Now().UTC().Format("20060102150405")}, nil
+}
+
+// NewRecordID returns a new random WARC-Record-ID.
+func NewRecordID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Write appends recs to the current file, starting a new file first when
+// the current one has reached the size limit. The records of one call are
+// kept in the same file.
+func (w *Writer) Write(recs ...Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil || w.size >= w.cfg.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	for _, r := range recs {
+		if err := w.write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Files returns the paths of the files written so far.
+func (w *Writer) Files() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.files...)
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		return fmt.Errorf("warc: close: %w", err)
+	}
+	return nil
+}
+
+// rotate closes the current file and opens the next one, starting it with
+// a warcinfo record.
+func (w *Writer) rotate() error {
+	if w.f != nil {
+		if err := w.f.Close(); err != nil {
+			return fmt.Errorf("warc: close: %w", err)
+		}
+		w.f = nil
+	}
+	w.seq++
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.cfg.Prefix, w.stamp, w.seq)
+	path := filepath.Join(w.cfg.Dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("warc: create %s: %w", path, err)
+	}
+	w.f, w.size = f, 0
+	w.files = append(w.files, path)
+
+	info := "format: WARC File Format 1.1\r\n" +
+		"conformsTo: https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n"
+	if w.cfg.Software != "" {
+		info = "software: " + w.cfg.Software + "\r\n" + info
+	}
+	return w.write(Record{
+		Type:        TypeWarcinfo,
+		Date:        time.Now(),
+		ContentType: ContentTypeFields,
+		Fields:      map[string]string{"WARC-Filename": name},
+		Payload:     strings.NewReader(info),
+	})
+}
+
+// write appends r to the current file as its own gzip member.
+func (w *Writer) write(r Record) error {
+	// Digest the block and the payload in one pass, then rewind for writing.
+	blockHash, payloadHash := sha256.New(), sha256.New()
+	blockHash.Write(r.HTTPHeader)
+	var payloadLen int64
+	if r.Payload != nil {
+		if _, err := r.Payload.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("warc: rewind payload: %w", err)
+		}
+		n, err := io.Copy(io.MultiWriter(blockHash, payloadHash), r.Payload)
+		if err != nil {
+			return fmt.Errorf("warc: read payload: %w", err)
+		}
+		if _, err := r.Payload.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("warc: rewind payload: %w", err)
+		}
+		payloadLen = n
+	}
+
+	id := r.ID
+	if id == "" {
+		id = NewRecordID()
+	}
+	var head bytes.Buffer
+	head.WriteString("WARC/1.1\r\n")
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&head, "%s: %s\r\n", name, value)
+		}
+	}
+	field("WARC-Type", r.Type)
+	field("WARC-Record-ID", id)
+	field("WARC-Date", r.Date.UTC().Format("2006-01-02T15:04:05.000000Z"))
+	field("WARC-Target-URI", r.TargetURI)
+	names := make([]string, 0, len(r.Fields))
+	for name := range r.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field(name, r.Fields[name])
+	}
+	field("WARC-Block-Digest", formatDigest(blockHash))
+	if r.HTTPHeader != nil {
+		field("WARC-Payload-Digest", formatDigest(payloadHash))
+	}
+	field("Content-Type", r.ContentType)
+	field("Content-Length", fmt.Sprint(int64(len(r.HTTPHeader))+payloadLen))
+	head.WriteString("\r\n")
+
+	cw := &countingWriter{w: w.f}
+	zw := gzip.NewWriter(cw)
+	if _, err := zw.Write(head.Bytes()); err != nil {
+		return fmt.Errorf("warc: write: %w", err)
+	}
+	if _, err := zw.Write(r.HTTPHeader); err != nil {
+		return fmt.Errorf("warc: write: %w", err)
+	}
+	if r.Payload != nil {
+		if _, err := io.Copy(zw, r.Payload); err != nil {
+			return fmt.Errorf("warc: write: %w", err)
+		}
+	}
+	if _, err := zw.Write([]byte("\r\n\r\n")); err != nil {
+		return fmt.Errorf("warc: write: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("warc: write: %w", err)
+	}
+	w.size += cw.n
+	return nil
+}
+
+// formatDigest formats a digest as "sha256:<base32>".
+func formatDigest(h hash.Hash) string {
+	return "sha256:" + base32.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,210 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readMembers returns the decompressed gzip members of path.
+func readMembers(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []string
+	for {
+		zr.Multistream(false)
+		data, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, string(data))
+		if err := zr.Reset(br); err == io.EOF {
+			return members
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWriterRecords(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(Config{Dir: dir, Prefix: "test", Software: "golm-connector"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n")
+	body := "<html>hello</html>"
+	err = w.Write(Record{
+		Type:        TypeResponse,
+		TargetURI:   "https://example.com/",
+		Date:        time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		ContentType: ContentTypeResponse,
+		HTTPHeader:  head,
+		Payload:     strings.NewReader(body),
+	})
+	if err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := w.Files()
+	if len(files) != 1 || !strings.HasSuffix(files[0], "-00001.warc.gz") {
+		t.Fatalf("Files() = %v", files)
+	}
+	members := readMembers(t, files[0])
+	if len(members) != 2 {
+		t.Fatalf("got %d gzip members, want warcinfo + response", len(members))
+	}
+	if !strings.Contains(members[0], "WARC-Type: warcinfo\r\n") || !strings.Contains(members[0], "software: golm-connector") {
+		t.Errorf("first record is not warcinfo:\n%s", members[0])
+	}
+
+	rec := members[1]
+	for _, want := range []string{
+		"WARC/1.1\r\n",
+		"WARC-Type: response\r\n",
+		"WARC-Target-URI: https://example.com/\r\n",
+		"WARC-Date: 2025-01-02T03:04:05.000000Z\r\n",
+		"WARC-Payload-Digest: sha256:",
+		"Content-Length: " + strconv.Itoa(len(head)+len(body)) + "\r\n",
+	} {
+		if !strings.Contains(rec, want) {
+			t.Errorf("response record lacks %q:\n%s", want, rec)
+		}
+	}
+	_, block, _ := strings.Cut(rec, "\r\n\r\n")
+	if block != string(head)+body+"\r\n\r\n" {
+		t.Errorf("block = %q", block)
+	}
+}
+
+func TestWriterRotates(t *testing.T) {
+	w, err := NewWriter(Config{Dir: t.TempDir(), MaxSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		err := w.Write(Record{Type: TypeMetadata, TargetURI: "https://example.com/", Date: time.Now(),
+			ContentType: ContentTypeFields, Payload: bytes.NewReader([]byte("n: " + strconv.Itoa(i) + "\r\n"))})
+		if err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := w.Files()
+	if len(files) != 3 {
+		t.Fatalf("got %d files, want 3", len(files))
+	}
+	for _, f := range files {
+		if m := readMembers(t, f); len(m) != 2 || !strings.Contains(m[0], "WARC-Type: warcinfo") {
+			t.Errorf("%s: %d records, want warcinfo + metadata", f, len(m))
+		}
+	}
+}
+
+func TestReaderRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, uri := range []string{"https://example.com/a", "https://example.com/b"} {
+		err := w.Write(Record{
+			Type:        TypeResponse,
+			TargetURI:   uri,
+			Date:        date,
+			ContentType: ContentTypeResponse,
+			HTTPHeader:  []byte("HTTP/1.1 200 OK\r\n\r\n"),
+			Payload:     strings.NewReader("body of " + uri),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(w.Files()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for {
+		h, block, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if h.Type != TypeResponse {
+			continue
+		}
+		if !h.Date.Equal(date) {
+			t.Errorf("%s: Date = %v, want %v", h.TargetURI, h.Date, date)
+		}
+		data, err := io.ReadAll(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int64(len(data)) != h.Length {
+			t.Errorf("%s: read %d bytes, Length = %d", h.TargetURI, len(data), h.Length)
+		}
+		got = append(got, h.TargetURI)
+	}
+	if strings.Join(got, " ") != "https://example.com/a https://example.com/b" {
+		t.Errorf("responses = %v", got)
+	}
+}
+
+func TestReaderUncompressed(t *testing.T) {
+	data := "WARC/1.0\r\n" +
+		"WARC-Type: resource\r\n" +
+		"WARC-Target-URI: <file:///site/index.html>\r\n" +
+		"Content-Type: text/html\r\n" +
+		"Content-Length: 6\r\n" +
+		"\r\n" +
+		"<html>\r\n\r\n"
+	r, err := NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, block, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	body, _ := io.ReadAll(block)
+	if h.TargetURI != "file:///site/index.html" || h.ContentType != "text/html" || string(body) != "<html>" {
+		t.Errorf("record = %+v, block %q", h, body)
+	}
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Errorf("second Next err = %v, want io.EOF", err)
+	}
+}